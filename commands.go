@@ -0,0 +1,639 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dcilke/heron"
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/dcilke/hz/pkg/trace"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+// addCommands registers the subcommands. Without a subcommand hz formats its
+// input, so hz [FILE] keeps working.
+func addCommands(parser *flags.Parser, cmd *Cmd) {
+	parser.SubcommandsOptional = true
+	_, _ = parser.AddCommand("config", "manage config files", "", &configCommand{
+		Check: configCheckCommand{cmd: cmd, out: os.Stdout},
+		Init:  configInitCommand{cmd: cmd, out: os.Stdout},
+	})
+	_, _ = parser.AddCommand("preview", "preview colored and raw output", "", &previewCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("view", "browse the input in an interactive pager", "", &viewCommand{cmd: cmd})
+	_, _ = parser.AddCommand("stats", "summarize the input", "", &statsCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("top", "count the most frequent values of a field", "", &topCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("agg", "aggregate the values of a numeric field", "", &aggCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("rate", "count records per level over time", "", &rateCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("errors", "group errors by fingerprint", "", &errorsCommand{cmd: cmd, out: os.Stdout})
+	_, _ = parser.AddCommand("traces", "group records into trees of spans by trace", "", &tracesCommand{cmd: cmd, out: os.Stdout})
+}
+
+// fileArgs returns args with the positional argument changed to ./NAME when it
+// is the only one and names both a command and an existing file, so hz FILE
+// keeps reading a file called e.g. stats. hz stats FILE still runs the
+// command, and arguments after -- are always files.
+func fileArgs(parser *flags.Parser, args []string) []string {
+	pos := positionals(parser, args)
+	if len(pos) != 1 {
+		return args
+	}
+	i := pos[0]
+	if i > 0 && args[i-1] == "--" {
+		return args
+	}
+	if parser.Find(args[i]) == nil {
+		return args
+	}
+	if _, err := os.Stat(args[i]); err != nil {
+		return args
+	}
+	out := append([]string(nil), args...)
+	out[i] = "." + string(filepath.Separator) + args[i]
+	return out
+}
+
+// positionals returns the indexes of the arguments which are not options or
+// their values.
+func positionals(parser *flags.Parser, args []string) []int {
+	var pos []int
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for j := i + 1; j < len(args); j++ {
+				pos = append(pos, j)
+			}
+			return pos
+		case strings.HasPrefix(arg, "--"):
+			name := arg[2:]
+			if strings.Contains(name, "=") {
+				continue
+			}
+			if takesValue(parser.FindOptionByLongName(name)) {
+				i++
+			}
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j, r := range arg[1:] {
+				if takesValue(parser.FindOptionByShortName(r)) {
+					// the rest of the argument, or the next one, is the value
+					if j+len(string(r)) == len(arg)-1 {
+						i++
+					}
+					break
+				}
+			}
+		default:
+			pos = append(pos, i)
+		}
+	}
+	return pos
+}
+
+// takesValue reports whether opt is followed by a value.
+func takesValue(opt *flags.Option) bool {
+	if opt == nil || opt.OptionalArgument {
+		return false
+	}
+	return reflect.TypeOf(opt.Value()).Kind() != reflect.Bool
+}
+
+// configCommand groups the subcommands for managing config files.
+type configCommand struct {
+	Check configCheckCommand `command:"check" description:"validate the config files"`
+	Init  configInitCommand  `command:"init" description:"write a commented starter config file"`
+}
+
+// configCheckCommand validates the config files which would be loaded.
+type configCheckCommand struct {
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *configCheckCommand) Execute(args []string) error {
+	files := configFiles(c.cmd.Config)
+	if len(files) == 0 {
+		fmt.Fprintln(c.out, "no config files found")
+		return nil
+	}
+
+	var errs []error
+	profiles := make(map[string]profile)
+	defaultProfile := ""
+	for _, file := range files {
+		var cfg Config
+		_, err := readConfig(file, &cfg)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			fmt.Fprintf(c.out, "%s: ok\n", file)
+		}
+		if cfg.DefaultProfile != "" {
+			defaultProfile = cfg.DefaultProfile
+		}
+		for n, p := range cfg.Profiles {
+			profiles[n] = profile{node: p, file: file}
+		}
+	}
+
+	if _, ok := profiles[defaultProfile]; defaultProfile != "" && !ok {
+		errs = append(errs, fmt.Errorf("default_profile: unknown profile %q", defaultProfile))
+	}
+	names := make([]string, 0, len(profiles))
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if err := applyProfile(&Cmd{}, make(Sources), profiles, n, nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", profiles[n].file, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configInitCommand writes a starter config file listing every option.
+type configInitCommand struct {
+	Force  bool `short:"f" long:"force" description:"overwrite an existing config file"`
+	Stdout bool `long:"stdout" description:"write the config to stdout instead"`
+
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *configInitCommand) Execute(args []string) error {
+	cfg, err := starterConfig()
+	if err != nil {
+		return err
+	}
+	if c.Stdout {
+		_, err := io.WriteString(c.out, cfg)
+		return err
+	}
+
+	path := c.cmd.Config
+	if path == "" {
+		path = cfgPath
+	}
+	if _, err := os.Stat(path); err == nil && !c.Force {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(cfg), 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.out, "wrote %s\n", path)
+	return nil
+}
+
+// starterConfig returns a config file with every option commented out at its
+// default value.
+func starterConfig() (string, error) {
+	var sb strings.Builder
+	sb.WriteString("# hz config, options set here are overridden by HZ_* environment variables\n")
+	sb.WriteString("# and command line flags. Run `hz config check` after editing.\n")
+
+	t := reflect.TypeOf(Cmd{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key := yamlKey(f)
+		if key == "" {
+			continue
+		}
+		value, err := flowValue(reflect.Zero(f.Type).Interface())
+		if err != nil {
+			return "", err
+		}
+		b, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&sb, "\n# %s\n# %s: %s", f.Tag.Get("description"), key, b)
+	}
+
+	sb.WriteString(`
+# profiles override the options above, select one with --profile or HZ_PROFILE
+# default_profile: prod
+# profiles:
+#   prod:
+#     strict: true
+#   debug:
+#     extends: prod
+#     vertical: true
+`)
+	return sb.String(), nil
+}
+
+// previewCommand shows sample records as they are output with and without
+// color, under the other options given.
+type previewCommand struct {
+	cmd *Cmd
+	out io.Writer
+}
+
+// previews are the outputs shown, in order.
+var previews = []struct {
+	name string
+	opts []writer.Option
+}{
+	{"color", []writer.Option{writer.WithColor(true)}},
+	{"raw", []writer.Option{writer.WithColor(false)}},
+}
+
+// previewSamples are the records rendered by the preview.
+var previewSamples = []string{
+	`{"level":"trace","time":"2022-08-03T12:34:25Z","caller":"main.go:12","message":"entering handler"}`,
+	`{"level":"debug","time":"2022-08-03T12:34:25Z","caller":"main.go:20","message":"cache miss","key":"users:42"}`,
+	`{"level":"info","time":"2022-08-03T12:34:26Z","message":"request","method":"GET","path":"/api/users","status":200,"duration":12}`,
+	`{"level":"warn","time":"2022-08-03T12:34:27Z","message":"slow query","elapsed_ms":1532}`,
+	`{"level":"error","time":"2022-08-03T12:34:28Z","caller":"db.go:88","message":"query failed","error":"connection refused"}`,
+	`{"level":"fatal","time":"2022-08-03T12:34:29Z","message":"shutting down"}`,
+}
+
+func (c *previewCommand) Execute(args []string) error {
+	for i, t := range previews {
+		if i > 0 {
+			fmt.Fprintln(c.out)
+		}
+		fmt.Fprintf(c.out, "%s:\n", t.name)
+
+		opts := append(writerOptions(c.cmd), writer.WithOut(c.out))
+		w := writer.New(append(opts, t.opts...)...)
+		for _, s := range previewSamples {
+			var a any
+			d := json.NewDecoder(strings.NewReader(s))
+			d.UseNumber()
+			if err := d.Decode(&a); err != nil {
+				return err
+			}
+			if _, err := w.WriteAny(a); err != nil {
+				return err
+			}
+			if _, err := w.Println(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// statsCommand summarizes the records of the input files or stdin.
+type statsCommand struct {
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *statsCommand) Execute(args []string) error {
+	s := stats.New()
+	record, err := normalized(c.cmd, s.Record)
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithBytes(s.Text),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+	process(args, func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+	return s.Write(c.out)
+}
+
+// errorsCommand groups the records at error level and above by fingerprint.
+type errorsCommand struct {
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *errorsCommand) Execute(args []string) error {
+	g := stats.NewGroups()
+	record, err := normalized(c.cmd, g.Record)
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+	process(args, func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+
+	w := writer.New(append(writerOptions(c.cmd), writer.WithOut(c.out))...)
+	for i, grp := range g.Groups() {
+		if i > 0 {
+			fmt.Fprintln(c.out)
+		}
+		fmt.Fprintf(c.out, "%s\n", grp.Fingerprint)
+		tw := tabwriter.NewWriter(c.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "  count\t%d\n", grp.Count)
+		if !grp.First.IsZero() {
+			fmt.Fprintf(tw, "  first\t%s\n", grp.First.Format(time.RFC3339))
+			fmt.Fprintf(tw, "  last\t%s\n", grp.Last.Format(time.RFC3339))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprint(c.out, "  ")
+		if _, err := w.WriteAny(grp.Example); err != nil {
+			return err
+		}
+		if _, err := w.Println(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// topCommand counts the most frequent values of a field.
+type topCommand struct {
+	Limit   int  `short:"n" long:"limit" default:"10" description:"number of values to show, 0 shows all"`
+	ByLevel bool `short:"L" long:"by-level" description:"count the values separately for each level"`
+	Args    struct {
+		Field string   `positional-arg-name:"FIELD" description:"field to count, nested fields are joined with a dot" required:"yes"`
+		Files []string `positional-arg-name:"FILE"`
+	} `positional-args:"yes"`
+
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *topCommand) Execute(args []string) error {
+	t := stats.NewTop(c.Args.Field,
+		stats.WithLevels(c.cmd.Level),
+		stats.WithByLevel(c.ByLevel),
+		stats.WithLimit(c.Limit),
+	)
+	record, err := normalized(c.cmd, t.Record)
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+	process(append(c.Args.Files, args...), func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+	return t.Write(c.out)
+}
+
+// aggCommand aggregates the values of a numeric field.
+type aggCommand struct {
+	By   string `short:"b" long:"by" description:"aggregate separately for each value of this field" value-name:"FIELD"`
+	Bins int    `long:"bins" default:"10" description:"number of histogram bins, 0 disables the histogram"`
+	Args struct {
+		Field string   `positional-arg-name:"FIELD" description:"numeric field to aggregate, nested fields are joined with a dot" required:"yes"`
+		Files []string `positional-arg-name:"FILE"`
+	} `positional-args:"yes"`
+
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *aggCommand) Execute(args []string) error {
+	agg := stats.NewAgg(c.Args.Field,
+		stats.WithGroupBy(c.By),
+		stats.WithAggLevels(c.cmd.Level),
+		stats.WithBins(c.Bins),
+	)
+	record, err := normalized(c.cmd, agg.Record)
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+	process(append(c.Args.Files, args...), func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+	return agg.Write(c.out)
+}
+
+// clearScreen moves the cursor home and clears the terminal.
+const clearScreen = "\x1b[H\x1b[2J"
+
+// rateCommand counts the records per level in buckets of time.
+type rateCommand struct {
+	Interval time.Duration `short:"i" long:"interval" default:"1m" description:"width of each time bucket"`
+	Follow   bool          `short:"F" long:"follow" description:"redraw every second while reading, e.g. from tail -f"`
+
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *rateCommand) Execute(args []string) error {
+	r := stats.NewRate(c.Interval)
+	record, err := normalized(c.cmd, func(a any) {
+		if m, ok := a.(map[string]any); ok && !formatter.MatchLevels(m, c.cmd.Level) {
+			return
+		}
+		r.Record(a)
+	})
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+
+	draw := func() error {
+		return r.Write(c.out)
+	}
+	stop := func() {}
+	if c.Follow {
+		draw = func() error {
+			var buf bytes.Buffer
+			buf.WriteString(clearScreen)
+			if err := r.Write(&buf); err != nil {
+				return err
+			}
+			_, err := buf.WriteTo(c.out)
+			return err
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := time.NewTicker(time.Second)
+			defer t.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-t.C:
+					_ = draw()
+				}
+			}
+		}()
+		stop = func() {
+			close(done)
+			wg.Wait()
+		}
+	}
+
+	process(args, func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+	stop()
+	return draw()
+}
+
+// tracesCommand groups the records by trace and shows each trace as a tree of
+// spans.
+type tracesCommand struct {
+	Trace string `short:"t" long:"trace" value-name:"ID" description:"only show the traces whose id starts with ID"`
+
+	cmd *Cmd
+	out io.Writer
+}
+
+func (c *tracesCommand) Execute(args []string) error {
+	traces := trace.New()
+	record, err := normalized(c.cmd, func(a any) {
+		if m, ok := a.(map[string]any); ok && !formatter.MatchLevels(m, c.cmd.Level) {
+			return
+		}
+		traces.Record(a)
+	})
+	if err != nil {
+		return err
+	}
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithError(func(err error) {
+			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
+		}),
+	)
+	process(args, func(f *os.File) {
+		h.Process(f)
+	})
+	h.Flush()
+
+	t := &traceTree{out: c.out}
+	t.w = writer.New(append(writerOptions(c.cmd), writer.WithOut(&t.buf))...)
+	first := true
+	for _, tr := range traces.Traces() {
+		if !strings.HasPrefix(tr.ID, c.Trace) {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(c.out)
+		}
+		first = false
+		if err := t.trace(tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// traceTree writes traces as trees, with the records of each span below it.
+type traceTree struct {
+	out io.Writer
+	w   writer.Writer
+	buf bytes.Buffer
+}
+
+func (t *traceTree) trace(tr *trace.Trace) error {
+	spans := "spans"
+	if tr.Spans() == 1 {
+		spans = "span"
+	}
+	fmt.Fprintf(t.out, "trace %s (%d %s%s)\n", tr.ID, tr.Spans(), spans, duration(tr.Start, tr.Duration()))
+	if err := t.records("", len(tr.Roots) > 0, tr.Records); err != nil {
+		return err
+	}
+	for i, sp := range tr.Roots {
+		if err := t.span("", i == len(tr.Roots)-1, sp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *traceTree) span(prefix string, last bool, sp *trace.Span) error {
+	branch, indent := "├─ ", "│  "
+	if last {
+		branch, indent = "└─ ", "   "
+	}
+	fmt.Fprintf(t.out, "%s%s%s", prefix, branch, sp.ID)
+	if !sp.Start.IsZero() {
+		fmt.Fprintf(t.out, " (%s)", sp.Duration())
+	}
+	fmt.Fprintln(t.out)
+	prefix += indent
+	if err := t.records(prefix, len(sp.Children) > 0, sp.Records); err != nil {
+		return err
+	}
+	for i, c := range sp.Children {
+		if err := t.span(prefix, i == len(sp.Children)-1, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// records writes records below a node, continuing the line to the node's
+// children if it has any.
+func (t *traceTree) records(prefix string, children bool, records []map[string]any) error {
+	prefix += "   "
+	if children {
+		prefix = prefix[:len(prefix)-3] + "│  "
+	}
+	for _, r := range records {
+		t.buf.Reset()
+		// the tree shows the ids, so they are left out of the records
+		if _, err := t.w.WriteAny(trace.Strip(r)); err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(t.buf.String(), "\n"), "\n") {
+			fmt.Fprintf(t.out, "%s%s\n", prefix, line)
+		}
+	}
+	return nil
+}
+
+// duration formats the duration of a trace, nothing if its records have no
+// time.
+func duration(start time.Time, d time.Duration) string {
+	if start.IsZero() {
+		return ""
+	}
+	return ", " + d.String()
+}
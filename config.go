@@ -0,0 +1,316 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/dcilke/gu"
+	"github.com/jessevdk/go-flags"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	projectCfgName = ".hz.yml"
+
+	sourceDefault = "default"
+)
+
+var cfgPath string
+
+func init() {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		cfgPath = filepath.Join(xdg, "hz", "config.yml")
+		return
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		home = "." // fallback to current directory
+	}
+	cfgPath = filepath.Join(home, ".config", "hz", "config.yml")
+}
+
+// Config is the layout of config.yml. Top level options are the base which
+// every profile starts from.
+type Config struct {
+	Cmd            `yaml:",inline"`
+	DefaultProfile string               `yaml:"default_profile"`
+	Profiles       map[string]yaml.Node `yaml:"profiles"`
+}
+
+// Profile is a named set of options, optionally extending another profile.
+type Profile struct {
+	Extends string `yaml:"extends"`
+	Cmd     `yaml:",inline"`
+}
+
+// Sources records where the effective value of each option came from, keyed
+// by the option's yaml key.
+type Sources map[string]string
+
+// profile is a profile definition along with the file it was read from.
+type profile struct {
+	node yaml.Node
+	file string
+}
+
+// configFiles returns the config files to load, in increasing precedence. An
+// explicit path replaces discovery of the user and project config files.
+func configFiles(path string) []string {
+	if path != "" {
+		return []string{path}
+	}
+
+	var files []string
+	if _, err := os.Stat(cfgPath); err == nil {
+		files = append(files, cfgPath)
+	}
+	if p := findProjectConfig(); p != "" && p != cfgPath {
+		files = append(files, p)
+	}
+	return files
+}
+
+// findProjectConfig walks up from the working directory to the nearest .hz.yml.
+func findProjectConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		p := filepath.Join(dir, projectCfgName)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadDefaults merges the config files over cmd and applies the selected
+// profile, returning where each option was set. Problems found in the config
+// files are returned, the valid parts are still applied.
+func loadDefaults(cmd *Cmd, path string, name string) (Sources, error) {
+	sources := make(Sources)
+	profiles := make(map[string]profile)
+	defaultProfile := ""
+
+	var errs []error
+	for _, file := range configFiles(path) {
+		cfg := Config{Cmd: *cmd}
+		node, err := readConfig(file, &cfg)
+		if node == nil {
+			return sources, errors.Join(append(errs, err)...)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+		*cmd = cfg.Cmd
+		sources.set(node, file)
+
+		if cfg.DefaultProfile != "" {
+			defaultProfile = cfg.DefaultProfile
+		}
+		for n, p := range cfg.Profiles {
+			profiles[n] = profile{node: p, file: file}
+		}
+	}
+
+	if name == "" {
+		name = defaultProfile
+	}
+	if name != "" {
+		cmd.Profile = name
+		if err := applyProfile(cmd, sources, profiles, name, nil); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return sources, errors.Join(errs...)
+}
+
+// readConfig validates and decodes file into cfg. The parsed document is
+// returned unless the file could not be read or parsed.
+func readConfig(file string, cfg *Config) (*yaml.Node, error) {
+	bytes, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	var node yaml.Node
+	if err := yaml.Unmarshal(bytes, &node); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	verr := validateConfig(file, &node)
+	if err := node.Decode(cfg); err != nil {
+		return &node, errors.Join(verr, fmt.Errorf("%s: %w", file, err))
+	}
+	return &node, verr
+}
+
+// applyProfile applies the named profile, and the profiles it extends, over cmd.
+func applyProfile(cmd *Cmd, sources Sources, profiles map[string]profile, name string, seen []string) error {
+	if gu.Includes(seen, name) {
+		return fmt.Errorf("profile %q extends itself: %s", name, strings.Join(append(seen, name), " -> "))
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+
+	// resolve the profile being extended first so this profile overrides it
+	var base struct {
+		Extends string `yaml:"extends"`
+	}
+	if err := p.node.Decode(&base); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	if base.Extends != "" {
+		if err := applyProfile(cmd, sources, profiles, base.Extends, append(seen, name)); err != nil {
+			return err
+		}
+	}
+
+	prof := Profile{Cmd: *cmd}
+	if err := p.node.Decode(&prof); err != nil {
+		return fmt.Errorf("profile %q: %w", name, err)
+	}
+	*cmd = prof.Cmd
+	sources.set(&p.node, fmt.Sprintf("profile %s (%s)", name, p.file))
+	return nil
+}
+
+// set records source for each option key present in the mapping node.
+func (s Sources) set(node *yaml.Node, source string) {
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	keys := cmdKeys()
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if k := node.Content[i].Value; keys[k] != "" {
+			s[k] = source
+		}
+	}
+}
+
+// setFlags records options set by environment variables or on the command line.
+func (s Sources) setFlags(parser *flags.Parser) {
+	eachOption(parser.Groups(), func(o *flags.Option) {
+		key := yamlKey(o.Field())
+		if key == "" || !o.IsSet() {
+			return
+		}
+		if !o.IsSetDefault() {
+			s[key] = "flag --" + o.LongName
+			return
+		}
+		if env := o.EnvKeyWithNamespace(); env != "" {
+			if _, ok := os.LookupEnv(env); ok {
+				s[key] = "env " + env
+			}
+		}
+	})
+}
+
+func eachOption(groups []*flags.Group, fn func(*flags.Option)) {
+	for _, g := range groups {
+		for _, o := range g.Options() {
+			fn(o)
+		}
+		eachOption(g.Groups(), fn)
+	}
+}
+
+// printConfig writes the effective options as yaml, annotated with where each
+// value came from.
+func printConfig(w io.Writer, cmd *Cmd, sources Sources) error {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	if files := configFiles(cmd.Config); len(files) > 0 {
+		doc.HeadComment = "files: " + strings.Join(files, ", ")
+	}
+	if cmd.Profile != "" {
+		doc.HeadComment = strings.TrimSpace(doc.HeadComment + "\nprofile: " + cmd.Profile)
+	}
+
+	v := reflect.ValueOf(cmd).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := yamlKey(t.Field(i))
+		if key == "" {
+			continue
+		}
+		value, err := flowValue(v.Field(i).Interface())
+		if err != nil {
+			return err
+		}
+		value.LineComment = sourceDefault
+		if src, ok := sources[key]; ok {
+			value.LineComment = src
+		}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// flowValue encodes v as a yaml node which renders on a single line.
+func flowValue(v any) (*yaml.Node, error) {
+	var value yaml.Node
+	if err := value.Encode(v); err != nil {
+		return nil, err
+	}
+	if value.Kind == yaml.SequenceNode || value.Kind == yaml.MappingNode {
+		value.Style = yaml.FlowStyle
+	}
+	return &value, nil
+}
+
+// cmdKeys returns the yaml keys of the options in Cmd.
+func cmdKeys() map[string]string {
+	keys := make(map[string]string)
+	t := reflect.TypeOf(Cmd{})
+	for i := 0; i < t.NumField(); i++ {
+		if key := yamlKey(t.Field(i)); key != "" {
+			keys[key] = t.Field(i).Name
+		}
+	}
+	return keys
+}
+
+func yamlKey(f reflect.StructField) string {
+	key, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// preArgs are the options needed ahead of loading the config, parsed before
+// the remaining options are parsed over the loaded config.
+type preArgs struct {
+	Config  string `short:"c" long:"config" env:"HZ_CONFIG"`
+	Profile string `short:"p" long:"profile" env:"HZ_PROFILE"`
+}
+
+func parsePreArgs(args []string) preArgs {
+	var opts preArgs
+	parser := flags.NewParser(&opts, flags.IgnoreUnknown|flags.PassDoubleDash)
+	_, _ = parser.ParseArgs(args)
+	return opts
+}
@@ -0,0 +1,180 @@
+// Package hzlogr provides a logr.LogSink which renders records the way the hz
+// command line does.
+package hzlogr
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/go-logr/logr"
+)
+
+const (
+	KeyLogger = "logger"
+	// KeyBad holds a value given without a key.
+	KeyBad = "!BADKEY"
+)
+
+// Ensure we are adhering to the logr interfaces.
+var (
+	_ logr.LogSink          = (*LogSink)(nil)
+	_ logr.CallDepthLogSink = (*LogSink)(nil)
+)
+
+// Options configure a LogSink.
+type Options struct {
+	// Verbosity is the highest V level logged, 0 logs only Info.
+	Verbosity int
+	// Caller adds the file and line of the call.
+	Caller bool
+	// Now returns the time of a record, time.Now if nil.
+	Now func() time.Time
+}
+
+// LogSink is a logr.LogSink writing records through a writer.Writer, so they
+// get the same pins, formatters and colors as the hz command line.
+type LogSink struct {
+	opts  Options
+	w     writer.Writer
+	depth int
+
+	name   string
+	values map[string]any
+}
+
+// New returns a logr.Logger writing to out. wopts configure the writer as they
+// do for writer.New.
+func New(out io.Writer, opts Options, wopts ...writer.Option) logr.Logger {
+	return logr.New(NewLogSink(out, opts, wopts...))
+}
+
+// NewLogSink returns a LogSink writing to out.
+func NewLogSink(out io.Writer, opts Options, wopts ...writer.Option) *LogSink {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &LogSink{
+		opts:   opts,
+		w:      writer.New(append(wopts, writer.WithOut(out))...),
+		values: map[string]any{},
+	}
+}
+
+func (s *LogSink) Init(info logr.RuntimeInfo) {
+	s.depth += info.CallDepth
+}
+
+func (s *LogSink) Enabled(level int) bool {
+	return level <= s.opts.Verbosity
+}
+
+func (s *LogSink) Info(level int, msg string, kv ...any) {
+	s.write(Level(level), nil, msg, kv)
+}
+
+func (s *LogSink) Error(err error, msg string, kv ...any) {
+	s.write(formatter.LevelErrorStr, err, msg, kv)
+}
+
+func (s *LogSink) WithValues(kv ...any) logr.LogSink {
+	s2 := *s
+	s2.values = make(map[string]any, len(s.values)+len(kv)/2)
+	for k, v := range s.values {
+		s2.values[k] = v
+	}
+	add(s2.values, kv)
+	return &s2
+}
+
+// WithName appends name to the logger name, joined with a slash.
+func (s *LogSink) WithName(name string) logr.LogSink {
+	s2 := *s
+	if s.name != "" {
+		name = s.name + "/" + name
+	}
+	s2.name = name
+	return &s2
+}
+
+func (s *LogSink) WithCallDepth(depth int) logr.LogSink {
+	s2 := *s
+	s2.depth += depth
+	return &s2
+}
+
+func (s *LogSink) write(level string, err error, msg string, kv []any) {
+	m := make(map[string]any, 6+len(s.values)+len(kv)/2)
+	for k, v := range s.values {
+		m[k] = v
+	}
+	add(m, kv)
+
+	m[formatter.KeyTime] = s.opts.Now().Format(time.RFC3339Nano)
+	m[formatter.KeyLevel] = level
+	m[formatter.KeyMessage] = msg
+	if err != nil {
+		m[formatter.KeyError] = err.Error()
+	}
+	if s.name != "" {
+		m[KeyLogger] = s.name
+	}
+	if s.opts.Caller {
+		// skip write and the Info or Error method, along with the logr frames
+		if _, file, line, ok := runtime.Caller(s.depth + 2); ok {
+			m[formatter.KeyCaller] = filepath.Base(filepath.Dir(file)) + "/" + filepath.Base(file) + ":" + strconv.Itoa(line)
+		}
+	}
+
+	_, _ = s.w.WriteLine(m)
+}
+
+// Level returns the hz level name of the V level l: 0 is info, 1 debug and
+// anything higher trace.
+func Level(l int) string {
+	switch {
+	case l <= 0:
+		return formatter.LevelInfoStr
+	case l == 1:
+		return formatter.LevelDebugStr
+	}
+	return formatter.LevelTraceStr
+}
+
+// add adds the key value pairs kv to m. A key which is not a string is
+// formatted with fmt, a trailing value without a pair is kept under KeyBad.
+func add(m map[string]any, kv []any) {
+	for i := 0; i < len(kv); i += 2 {
+		k, ok := kv[i].(string)
+		if !ok {
+			k = fmt.Sprint(kv[i])
+		}
+		if i+1 == len(kv) {
+			m[KeyBad] = kv[i]
+			return
+		}
+		m[k] = value(kv[i+1])
+	}
+}
+
+// value converts v to what the writer expects to find in a decoded record.
+func value(v any) any {
+	switch vv := v.(type) {
+	case logr.Marshaler:
+		return value(vv.MarshalLog())
+	case error:
+		return vv.Error()
+	case time.Duration:
+		return vv.String()
+	case time.Time:
+		return vv.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return vv.String()
+	}
+	return v
+}
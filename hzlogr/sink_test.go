@@ -0,0 +1,73 @@
+package hzlogr_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/hzlogr"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+)
+
+var at = time.Date(2022, 8, 3, 12, 34, 25, 0, time.UTC)
+
+func now() time.Time { return at }
+
+func TestLogSink(t *testing.T) {
+	testcases := map[string]struct {
+		log  func(logr.Logger)
+		want string
+	}{
+		"message": {func(l logr.Logger) { l.Info("hello") }, "12:34:25 INF hello\n"},
+		"values": {func(l logr.Logger) {
+			l.Info("slow request", "path", "/api/users", "status", 200, "elapsed", 1532*time.Millisecond)
+		}, "12:34:25 INF slow request elapsed=1.532s path=/api/users status=200\n"},
+		"error": {func(l logr.Logger) {
+			l.Error(errors.New("connection refused"), "query failed")
+		}, "12:34:25 ERR query failed error=connection refused\n"},
+		"debug":    {func(l logr.Logger) { l.V(1).Info("details") }, "12:34:25 DBG details\n"},
+		"trace":    {func(l logr.Logger) { l.V(2).Info("deep") }, "12:34:25 TRC deep\n"},
+		"disabled": {func(l logr.Logger) { l.V(3).Info("hidden") }, ""},
+		"name": {func(l logr.Logger) {
+			l.WithName("api").WithName("users").Info("hello")
+		}, "12:34:25 INF hello logger=api/users\n"},
+		"with-values": {func(l logr.Logger) {
+			l.WithValues("service", "api").Info("started", "port", 8080)
+		}, "12:34:25 INF started port=8080 service=api\n"},
+		"bad-key": {func(l logr.Logger) {
+			l.Info("odd", "key", "value", "dangling")
+		}, "12:34:25 INF odd !BADKEY=dangling key=value\n"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			tc.log(hzlogr.New(buf, hzlogr.Options{Verbosity: 2, Now: now}, writer.WithColor(false)))
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestLogSink_Caller(t *testing.T) {
+	buf := new(bytes.Buffer)
+	hzlogr.New(buf, hzlogr.Options{Caller: true, Now: now}, writer.WithColor(false)).Info("with caller")
+	require.Contains(t, buf.String(), "hzlogr/sink_test.go:")
+	require.Contains(t, buf.String(), "with caller")
+}
+
+func TestLogSink_WithValuesIsolated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	l := hzlogr.New(buf, hzlogr.Options{Now: now}, writer.WithColor(false))
+	_ = l.WithValues("extra", "x")
+	l.Info("hello")
+	require.Equal(t, "12:34:25 INF hello\n", buf.String())
+}
+
+func TestLevel(t *testing.T) {
+	require.Equal(t, "info", hzlogr.Level(0))
+	require.Equal(t, "debug", hzlogr.Level(1))
+	require.Equal(t, "trace", hzlogr.Level(2))
+	require.Equal(t, "trace", hzlogr.Level(9))
+}
@@ -0,0 +1,162 @@
+// Package hzzap provides a zapcore.Encoder which renders entries the way the
+// hz command line does.
+package hzzap
+
+import (
+	"time"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/writer"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	KeyLogger     = "logger"
+	KeyStacktrace = "stacktrace"
+)
+
+// Ensure we are adhering to the zapcore.Encoder interface.
+var _ zapcore.Encoder = (*Encoder)(nil)
+
+var pool = buffer.NewPool()
+
+// Encoder is a zapcore.Encoder writing entries through a writer.Writer, so
+// they get the same pins, formatters and colors as the hz command line.
+type Encoder struct {
+	*zapcore.MapObjectEncoder
+	// w is shared by clones, so state such as aligned column widths carries
+	// across entries
+	w writer.Writer
+
+	// namespaces are the namespaces opened, in order
+	namespaces []string
+}
+
+// NewEncoder returns an Encoder configured with the writer options opts.
+func NewEncoder(opts ...writer.Option) *Encoder {
+	return newEncoder(writer.New(opts...))
+}
+
+func newEncoder(w writer.Writer) *Encoder {
+	return &Encoder{
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		w:                w,
+	}
+}
+
+// NewCore returns a zapcore.Core writing hz formatted entries to out.
+func NewCore(out zapcore.WriteSyncer, enab zapcore.LevelEnabler, opts ...writer.Option) zapcore.Core {
+	return zapcore.NewCore(NewEncoder(opts...), out, enab)
+}
+
+func (e *Encoder) OpenNamespace(key string) {
+	e.namespaces = append(e.namespaces, key)
+	e.MapObjectEncoder.OpenNamespace(key)
+}
+
+func (e *Encoder) Clone() zapcore.Encoder {
+	c := newEncoder(e.w)
+	m := c.Fields
+	for k, v := range clone(e.Fields) {
+		m[k] = v
+	}
+	// reopen the namespaces so later fields are added within them
+	for _, ns := range e.namespaces {
+		fields, _ := m[ns].(map[string]any)
+		c.OpenNamespace(ns)
+		cur := m[ns].(map[string]any)
+		for k, v := range fields {
+			cur[k] = v
+		}
+		m = cur
+	}
+	return c
+}
+
+func (e *Encoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	c := e.Clone().(*Encoder)
+	for _, f := range fields {
+		f.AddTo(c)
+	}
+
+	m := normalize(c.Fields).(map[string]any)
+	if !ent.Time.IsZero() {
+		m[formatter.KeyTime] = ent.Time.Format(time.RFC3339Nano)
+	}
+	m[formatter.KeyLevel] = Level(ent.Level)
+	m[formatter.KeyMessage] = ent.Message
+	if ent.Caller.Defined {
+		m[formatter.KeyCaller] = ent.Caller.TrimmedPath()
+	}
+	if ent.LoggerName != "" {
+		m[KeyLogger] = ent.LoggerName
+	}
+	if ent.Stack != "" {
+		m[KeyStacktrace] = ent.Stack
+	}
+
+	buf := pool.Get()
+	if _, err := e.w.To(buf).WriteLine(m); err != nil {
+		buf.Free()
+		return nil, err
+	}
+	return buf, nil
+}
+
+// Level returns the hz level name of l. DPanic is reported as panic and
+// levels below debug as trace.
+func Level(l zapcore.Level) string {
+	switch {
+	case l < zapcore.DebugLevel:
+		return formatter.LevelTraceStr
+	case l == zapcore.DebugLevel:
+		return formatter.LevelDebugStr
+	case l == zapcore.InfoLevel:
+		return formatter.LevelInfoStr
+	case l == zapcore.WarnLevel:
+		return formatter.LevelWarnStr
+	case l == zapcore.ErrorLevel:
+		return formatter.LevelErrorStr
+	case l == zapcore.FatalLevel:
+		return formatter.LevelFatalStr
+	}
+	return formatter.LevelPanicStr
+}
+
+// normalize converts the values zap keeps natively to what the writer expects
+// to find in a decoded record.
+func normalize(v any) any {
+	switch vv := v.(type) {
+	case map[string]any:
+		for k, f := range vv {
+			vv[k] = normalize(f)
+		}
+		return vv
+	case []any:
+		for i, f := range vv {
+			vv[i] = normalize(f)
+		}
+		return vv
+	case time.Duration:
+		return vv.String()
+	case time.Time:
+		return vv.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// clone copies the maps and slices of m so it can be added to without changing m.
+func clone(m map[string]any) map[string]any {
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		switch vv := v.(type) {
+		case map[string]any:
+			v = clone(vv)
+		case []any:
+			v = append([]any(nil), vv...)
+		}
+		c[k] = v
+	}
+	return c
+}
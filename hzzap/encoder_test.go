@@ -0,0 +1,133 @@
+package hzzap_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/hzzap"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var at = time.Date(2022, 8, 3, 12, 34, 25, 0, time.UTC)
+
+func entry(level zapcore.Level, msg string) zapcore.Entry {
+	return zapcore.Entry{Level: level, Time: at, Message: msg}
+}
+
+func TestEncoder(t *testing.T) {
+	testcases := map[string]struct {
+		with   []zapcore.Field
+		entry  zapcore.Entry
+		fields []zapcore.Field
+		want   string
+	}{
+		"message": {nil, entry(zapcore.InfoLevel, "hello"), nil, "12:34:25 INF hello\n"},
+		"fields": {nil, entry(zapcore.WarnLevel, "slow request"), []zapcore.Field{
+			zap.String("path", "/api/users"),
+			zap.Int("status", 200),
+			zap.Bool("cached", false),
+			zap.Duration("elapsed", 1532*time.Millisecond),
+		}, "12:34:25 WRN slow request cached=false elapsed=1.532s path=/api/users status=200\n"},
+		"error": {nil, entry(zapcore.ErrorLevel, "query failed"), []zapcore.Field{
+			zap.Error(errors.New("connection refused")),
+		}, "12:34:25 ERR query failed error=connection refused\n"},
+		"caller": {nil, zapcore.Entry{
+			Level:   zapcore.InfoLevel,
+			Time:    at,
+			Message: "hello",
+			Caller:  zapcore.EntryCaller{Defined: true, File: "/src/app/main.go", Line: 12},
+		}, nil, "12:34:25 INF app/main.go:12 > hello\n"},
+		"logger": {nil, zapcore.Entry{Level: zapcore.InfoLevel, Time: at, Message: "hello", LoggerName: "api"}, nil, "12:34:25 INF hello logger=api\n"},
+		"debug":  {nil, entry(zapcore.DebugLevel, "details"), nil, "12:34:25 DBG details\n"},
+		"dpanic": {nil, entry(zapcore.DPanicLevel, "unexpected"), nil, "12:34:25 PNC unexpected\n"},
+		"fatal":  {nil, entry(zapcore.FatalLevel, "exiting"), nil, "12:34:25 FTL exiting\n"},
+		"with": {
+			[]zapcore.Field{zap.String("service", "api")},
+			entry(zapcore.InfoLevel, "started"),
+			[]zapcore.Field{zap.Int("port", 8080)},
+			"12:34:25 INF started port=8080 service=api\n",
+		},
+		"namespace": {
+			[]zapcore.Field{zap.Namespace("req"), zap.String("id", "abc")},
+			entry(zapcore.InfoLevel, "request"),
+			[]zapcore.Field{zap.Int("status", 200)},
+			"12:34:25 INF request req={\"id\":\"abc\",\"status\":200}\n",
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			var enc zapcore.Encoder = hzzap.NewEncoder(writer.WithColor(false))
+			if tc.with != nil {
+				enc = enc.Clone()
+				for _, f := range tc.with {
+					f.AddTo(enc)
+				}
+			}
+			buf, err := enc.EncodeEntry(tc.entry, tc.fields)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, buf.String())
+		})
+	}
+}
+
+func TestEncoder_CloneIsolated(t *testing.T) {
+	enc := hzzap.NewEncoder(writer.WithColor(false))
+	enc.AddString("service", "api")
+	c := enc.Clone()
+	c.AddString("extra", "x")
+
+	buf, err := enc.EncodeEntry(entry(zapcore.InfoLevel, "hello"), nil)
+	require.NoError(t, err)
+	require.Equal(t, "12:34:25 INF hello service=api\n", buf.String())
+}
+
+func TestEncoder_Align(t *testing.T) {
+	enc := hzzap.NewEncoder(writer.WithColor(false), writer.WithAlign(true))
+	caller := func(file string, msg string) zapcore.Entry {
+		e := entry(zapcore.InfoLevel, msg)
+		e.Caller = zapcore.EntryCaller{Defined: true, File: file, Line: 1}
+		return e
+	}
+	var out []string
+	for _, e := range []zapcore.Entry{
+		caller("/src/app/a.go", "first"),
+		caller("/src/app/server.go", "second"),
+		caller("/src/app/a.go", "third"),
+	} {
+		// clones, as zap makes for loggers with fields, share the columns
+		buf, err := enc.Clone().EncodeEntry(e, nil)
+		require.NoError(t, err)
+		out = append(out, buf.String())
+	}
+	require.Equal(t, []string{
+		"12:34:25 INF app/a.go:1 > first\n",
+		"12:34:25 INF app/server.go:1 > second\n",
+		"12:34:25 INF app/a.go:1 >      third\n",
+	}, out)
+}
+
+func TestNewCore(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := zap.New(hzzap.NewCore(zapcore.AddSync(buf), zapcore.InfoLevel, writer.WithColor(false))).
+		With(zap.String("service", "api"))
+	logger.Debug("hidden")
+	logger.Info("started", zap.Int("port", 8080))
+	require.NotContains(t, buf.String(), "hidden")
+	require.Contains(t, buf.String(), "INF started port=8080 service=api")
+}
+
+func TestLevel(t *testing.T) {
+	require.Equal(t, "trace", hzzap.Level(zapcore.DebugLevel-1))
+	require.Equal(t, "debug", hzzap.Level(zapcore.DebugLevel))
+	require.Equal(t, "info", hzzap.Level(zapcore.InfoLevel))
+	require.Equal(t, "warn", hzzap.Level(zapcore.WarnLevel))
+	require.Equal(t, "error", hzzap.Level(zapcore.ErrorLevel))
+	require.Equal(t, "panic", hzzap.Level(zapcore.DPanicLevel))
+	require.Equal(t, "panic", hzzap.Level(zapcore.PanicLevel))
+	require.Equal(t, "fatal", hzzap.Level(zapcore.FatalLevel))
+}
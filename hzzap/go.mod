@@ -0,0 +1,24 @@
+module github.com/dcilke/hz/hzzap
+
+go 1.20
+
+require (
+	github.com/dcilke/hz v0.0.0-20261019102304-81eee61fb8c4
+	github.com/stretchr/testify v1.8.2
+	go.uber.org/zap v1.24.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dcilke/gu v0.1.0 // indirect
+	github.com/mattn/go-colorable v0.1.12 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	go.uber.org/atomic v1.7.0 // indirect
+	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+// builds against the hz of this checkout, consumers use the version required
+replace github.com/dcilke/hz => ../
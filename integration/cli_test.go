@@ -1,6 +1,9 @@
 package integration
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/dcilke/golden"
@@ -52,12 +55,46 @@ func TestCLI_Help(t *testing.T) {
 	}
 }
 
+func TestCLI_FileNamedCommand(t *testing.T) {
+	dir := t.TempDir()
+	ndjson, err := os.ReadFile(fn("ndjson"))
+	require.NoError(t, err)
+	write(t, filepath.Join(dir, "stats"), string(ndjson))
+	write(t, filepath.Join(dir, "top"), string(ndjson))
+
+	testcases := map[string][]string{
+		"file":        {"stats"},
+		"flags":       {"--raw", "-l", "error", "stats"},
+		"double-dash": {"--level", "error", "--", "top"},
+		"command":     {"stats", "stats"},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hzIn(dir, args...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
 func TestCLI_Flat(t *testing.T) {
 	output, err := hz(fn("nested"), "--raw", "--flat")
 	require.NoError(t, err)
 	golden.Assert(t, output)
 }
 
+func TestCLI_FlatDepth(t *testing.T) {
+	output, err := hz(fn("nested"), "--raw", "--flat", "--flat-depth", "1")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_FlatSep(t *testing.T) {
+	output, err := hz(fn("nested"), "--raw", "--flat", "--flat-sep", "_")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
 func TestCLI_Vert(t *testing.T) {
 	output, err := hz(fn("nested"), "--raw", "--vertical")
 	require.NoError(t, err)
@@ -75,3 +112,452 @@ func TestCLI_NoPin(t *testing.T) {
 	require.NoError(t, err)
 	golden.Assert(t, output)
 }
+
+func TestCLI_MaxValueLen(t *testing.T) {
+	output, err := hz(fn("nested"), "--raw", "--max-value-len", "8")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Wrap(t *testing.T) {
+	t.Setenv("COLUMNS", "40")
+	output, err := hz(fn("ndjson"), "--raw", "--wrap")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Align(t *testing.T) {
+	output, err := hz(fn("mixed"), "--raw", "--align")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Humanize(t *testing.T) {
+	output, err := hz(fn("mixed"), "--humanize", "--humanize-field", "elapsed:duration_s")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Summary(t *testing.T) {
+	for _, tc := range filecases {
+		t.Run(tc, func(t *testing.T) {
+			output, err := hz(fn(tc), "--raw", "--level", "error", "--summary")
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+
+	// the input is counted, not the records the query turns it into
+	t.Run("select", func(t *testing.T) {
+		output, err := hz(fn("ndjson"), "--raw", "--select", "{message}", "--summary")
+		require.NoError(t, err)
+		golden.Assert(t, output)
+	})
+}
+
+func TestCLI_Script(t *testing.T) {
+	output, err := hz(fn("requests"), "--raw", "--script", filepath.Join("testdata", "scripts", "requests.star"))
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_ScriptError(t *testing.T) {
+	output, err := hz(fn("requests"), "--raw", "--script", filepath.Join("testdata", "scripts", "missing.star"))
+	require.Error(t, err)
+	require.Contains(t, string(output), "unable to load script")
+}
+
+func TestCLI_Normalize(t *testing.T) {
+	config(t, t.TempDir(), `
+rename:
+  lvl: level
+  ctx.msg: message
+  ctx.ts: time
+values:
+  level: {"30": info, "40": warn, "50": error}
+  env: {p: prod, s: staging}
+labels:
+  where: "{env}/{host}"
+`)
+
+	testcases := map[string][]string{
+		"default": nil,
+		"level":   {"--level", "error"},
+		"flag":    {"--rename", "svc:service", "--label", "route:{method} {path}"},
+		"stats":   {"stats"},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(append([]string{"--raw"}, append(args, fn("vocabulary"))...)...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_Schema(t *testing.T) {
+	testcases := map[string][]string{
+		"ecs":        {"--schema", "ecs", fn("ecs")},
+		"otel":       {"--schema", "otel", fn("otel")},
+		"auto":       {"--schema", "auto", fn("ecs"), fn("otel"), fn("ndjson")},
+		"otel-level": {"--schema", "otel", "--level", "error", fn("otel")},
+		"otel-stats": {"--schema", "otel", "stats", fn("otel")},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(append([]string{"--raw"}, args...)...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_SchemaError(t *testing.T) {
+	config(t, t.TempDir(), "schema: gelf\n")
+
+	output, err := hz(fn("ecs"), "--raw")
+	require.Error(t, err)
+	require.Contains(t, string(output), `unknown schema "gelf"`)
+
+	output, err = hz("--raw", "stats", fn("ecs"))
+	require.Error(t, err)
+	require.Contains(t, string(output), `unknown schema "gelf"`)
+}
+
+func TestCLI_Select(t *testing.T) {
+	testcases := map[string][]string{
+		"object":  {"--select", "{msg: .message, method}"},
+		"fields":  {"--select", `{level, message, request: {method, path}}`},
+		"scalar":  {"--select", ".path"},
+		"level":   {"--select", "{level, path}", "--level", "warn", "--level", "error"},
+		"dropped": {"--select", "{message: .message}", "--level", "error"},
+		"failed":  {"--select", ".status[]", "--level", "error"},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(append([]string{fn("requests"), "--raw"}, args...)...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_SelectMixed(t *testing.T) {
+	output, err := hz(fn("mixed"), "--raw", "--select", "{msg: .message}")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_SelectError(t *testing.T) {
+	output, err := hz(fn("requests"), "--raw", "--select", "{msg: .message")
+	require.Error(t, err)
+	require.Contains(t, string(output), "unable to parse --select: column 15")
+}
+
+func TestCLI_Plugin(t *testing.T) {
+	route, err := filepath.Abs(filepath.Join("testdata", "plugins", "route.sh"))
+	require.NoError(t, err)
+	config(t, t.TempDir(), `
+plugins:
+  - name: route
+    command: [sh, `+route+`]
+`)
+
+	testcases := map[string][]string{
+		"pinned":   {"--raw"},
+		"unpinned": {"--raw", "--no-pin"},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(append([]string{fn("requests")}, args...)...)
+			require.NoError(t, err)
+			require.NotContains(t, string(output), "@plugin:")
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_PluginError(t *testing.T) {
+	config(t, t.TempDir(), `
+plugins:
+  - name: route
+`)
+
+	output, err := hz(fn("requests"), "--raw")
+	require.Error(t, err)
+	require.Contains(t, string(output), `unable to load plugin: plugin "route" has no command`)
+}
+
+func TestCLI_HTTP(t *testing.T) {
+	output, err := hz(fn("mixed"), "--raw", "--http")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Profile(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, `
+flat: true
+default_profile: terse
+profiles:
+  terse:
+    level: [warn, error]
+  verbose:
+    extends: terse
+    vertical: true
+`)
+
+	testcases := map[string]struct {
+		env  string
+		args []string
+	}{
+		"default":  {"", nil},
+		"flag":     {"", []string{"--profile", "verbose"}},
+		"env":      {"verbose", nil},
+		"override": {"", []string{"-p", "verbose", "--level", "info"}},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("HZ_PROFILE", tc.env)
+			output, err := hz(append([]string{fn("ndjson"), "--raw"}, tc.args...)...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_ProjectConfig(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, "flat: true\nlevel: [info]\n")
+	project := t.TempDir()
+	write(t, filepath.Join(project, ".hz.yml"), "vertical: true\n")
+	dir := filepath.Join(project, "sub", "dir")
+	write(t, filepath.Join(dir, ".keep"), "")
+
+	output, err := hzIn(dir, fn("ndjson"), "--raw")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_ConfigFlag(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, "vertical: true\n")
+	cfg := filepath.Join(t.TempDir(), "other.yml")
+	write(t, cfg, "level: [error]\n")
+
+	output, err := hz(fn("ndjson"), "--raw", "--config", cfg)
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Env(t *testing.T) {
+	config(t, t.TempDir(), "level: [info]\n")
+	t.Setenv("HZ_LEVEL", "warn,error")
+	t.Setenv("HZ_VERTICAL", "true")
+
+	output, err := hz(fn("ndjson"), "--raw")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_PrintConfig(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, `
+flat: true
+level: [info]
+profiles:
+  wide:
+    columns:
+      caller: 24
+`)
+	project := t.TempDir()
+	write(t, filepath.Join(project, ".hz.yml"), "vertical: true\n")
+	t.Setenv("HZ_MAX_VALUE_LEN", "80")
+
+	output, err := hzIn(project, "--print-config", "--profile", "wide", "--align")
+	require.NoError(t, err)
+	output = bytes.ReplaceAll(output, []byte(home), []byte("$HOME"))
+	output = bytes.ReplaceAll(output, []byte(project), []byte("$PROJECT"))
+	golden.Assert(t, output)
+}
+
+func TestCLI_ConfigCheck(t *testing.T) {
+	testcases := map[string]struct {
+		config string
+		valid  bool
+	}{
+		"valid":   {"raw: true\nlevel: [info]\n", true},
+		"alias":   {"plain: true\n", true},
+		"unknown": {"raww: true\nno-pin: true\nfoo: bar\n", false},
+		"twice":   {"raw: true\nplain: false\n", false},
+		"type":    {"flatDepth: deep\n", false},
+		"profiles": {`
+default_profile: missing
+profiles:
+  a:
+    extends: b
+    vertcal: true
+  b:
+    extends: a
+`, false},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			config(t, home, tc.config)
+			output, err := hz("config", "check")
+			if tc.valid {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+			golden.Assert(t, bytes.ReplaceAll(output, []byte(home), []byte("$HOME")))
+		})
+	}
+}
+
+func TestCLI_ConfigInvalid(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, "raw: true\nlevl: [error]\n")
+
+	output, err := hz(fn("ndjson"))
+	require.NoError(t, err)
+	golden.Assert(t, bytes.ReplaceAll(output, []byte(home), []byte("$HOME")))
+}
+
+func TestCLI_ConfigInvalidProject(t *testing.T) {
+	home := t.TempDir()
+	config(t, home, "raw: true\nlevl: [error]\n")
+	project := t.TempDir()
+	write(t, filepath.Join(project, ".hz.yml"), "flat: [\n")
+
+	output, err := hzIn(project, fn("ndjson"))
+	require.NoError(t, err)
+	output = bytes.ReplaceAll(output, []byte(home), []byte("$HOME"))
+	golden.Assert(t, bytes.ReplaceAll(output, []byte(project), []byte("$PROJECT")))
+}
+
+func TestCLI_ConfigInit(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+
+	output, err := hz("config", "init")
+	require.NoError(t, err)
+	golden.Assert(t, bytes.ReplaceAll(output, []byte(home), []byte("$HOME")))
+
+	// the starter config is valid as written
+	output, err = hz("config", "check")
+	require.NoError(t, err, string(output))
+
+	// an existing config is left alone
+	_, err = hz("config", "init")
+	require.Error(t, err)
+	_, err = hz("config", "init", "--force")
+	require.NoError(t, err)
+}
+
+func TestCLI_ConfigInitStdout(t *testing.T) {
+	output, err := hz("config", "init", "--stdout")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Preview(t *testing.T) {
+	output, err := hz("preview")
+	require.NoError(t, err)
+	golden.Assert(t, output)
+}
+
+func TestCLI_Stats(t *testing.T) {
+	for _, tc := range filecases {
+		t.Run(tc, func(t *testing.T) {
+			output, err := hz("stats", fn(tc))
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_Errors(t *testing.T) {
+	for _, tc := range filecases {
+		t.Run(tc, func(t *testing.T) {
+			output, err := hz("errors", "--raw", fn(tc))
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+
+	t.Run("grouped", func(t *testing.T) {
+		output, err := hz("errors", "--raw", fn("errors"))
+		require.NoError(t, err)
+		golden.Assert(t, output)
+	})
+}
+
+func TestCLI_Top(t *testing.T) {
+	testcases := map[string][]string{
+		"message":  {"top", "message", fn("mixed")},
+		"nested":   {"top", "log.level", fn("mixed"), "--raw"},
+		"limit":    {"top", "-n", "2", "module", fn("mixed")},
+		"by-level": {"top", "--by-level", "module", fn("mixed")},
+		"level":    {"top", "--level", "warn", "message", fn("mixed")},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(args...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_Agg(t *testing.T) {
+	testcases := map[string][]string{
+		"field": {"agg", "duration_ms", fn("requests")},
+		"by":    {"agg", "--by", "path", "--bins", "0", "duration_ms", fn("requests")},
+		"level": {"agg", "--level", "info", "--bins", "4", "bytes", fn("requests")},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(args...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_Rate(t *testing.T) {
+	testcases := map[string][]string{
+		"default":  {"rate", fn("requests")},
+		"interval": {"rate", "-i", "2s", fn("requests")},
+		"level":    {"rate", "-i", "2s", "--level", "info", fn("requests")},
+		"mixed":    {"rate", "-i", "1s", fn("mixed")},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(args...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
+
+func TestCLI_Traces(t *testing.T) {
+	testcases := map[string][]string{
+		"default": {"traces", fn("traces")},
+		"trace":   {"traces", "--trace", "a3ce", fn("traces")},
+		"level":   {"traces", "--level", "info", fn("traces")},
+		"otel":    {"traces", "--schema", "otel", fn("otel")},
+		"mixed":   {"traces", fn("mixed")},
+		"ecs":     {"traces", fn("spans")},
+		"flat":    {"traces", "--flat", fn("spans")},
+	}
+	for name, args := range testcases {
+		t.Run(name, func(t *testing.T) {
+			output, err := hz(args...)
+			require.NoError(t, err)
+			golden.Assert(t, output)
+		})
+	}
+}
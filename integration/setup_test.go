@@ -8,7 +8,10 @@ import (
 	"testing"
 )
 
-var command string
+var (
+	command  string
+	coverdir string
+)
 
 func TestMain(m *testing.M) {
 	root, err := os.Getwd()
@@ -17,15 +20,44 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 	command = filepath.Join(root, "..", "bin", "test", "hz")
+	coverdir = filepath.Join(root, "..", ".covdata")
 	os.Exit(m.Run())
 }
 
 func hz(args ...string) ([]byte, error) {
+	return hzIn("", args...)
+}
+
+// hzIn runs hz from dir, the current directory if empty.
+func hzIn(dir string, args ...string) ([]byte, error) {
 	cmd := exec.Command(command, args...)
-	cmd.Env = append(os.Environ(), "GOCOVERDIR=../.covdata")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOCOVERDIR="+coverdir)
 	return cmd.CombinedOutput()
 }
 
 func fn(file string) string {
-	return filepath.Join("testdata", "samples", file)
+	p, err := filepath.Abs(filepath.Join("testdata", "samples", file))
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// config writes the user config.yml under home and points HOME at it.
+func config(t *testing.T, home string, content string) {
+	t.Helper()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	write(t, filepath.Join(home, ".config", "hz", "config.yml"), content)
+}
+
+func write(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
 }
@@ -0,0 +1,11 @@
+#!/bin/sh
+# route pins the method and path of each request, see the plugins section of
+# the README for the protocol.
+while IFS= read -r line; do
+	route=$(printf '%s\n' "$line" | sed -n 's/.*"method":"\([A-Z]*\)".*"path":"\([^"]*\)".*/\1 \2/p')
+	if [ -n "$route" ]; then
+		printf '{"pin":"%s","exclude":["method","path"]}\n' "$route"
+	else
+		echo '{}'
+	fi
+done
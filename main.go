@@ -1,130 +1,335 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
-	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/dcilke/gu"
 	"github.com/dcilke/heron"
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/plugin"
+	"github.com/dcilke/hz/pkg/query"
+	"github.com/dcilke/hz/pkg/schema"
+	"github.com/dcilke/hz/pkg/script"
+	"github.com/dcilke/hz/pkg/stats"
 	"github.com/dcilke/hz/pkg/writer"
 	"github.com/jessevdk/go-flags"
-	"gopkg.in/yaml.v3"
 )
 
 const (
 	newline = "\n"
 )
 
-var cfgPath string
-
-func init() {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		home = "." // fallback to current directory
-	}
-	cfgPath = filepath.Join(home, ".config", "hz", "config.yml")
-}
-
 type Cmd struct {
-	Level    []string `short:"l" long:"level" description:"only output lines at this level" yaml:"level"`
-	Strict   bool     `short:"s" long:"strict" description:"exclude non JSON output" yaml:"strict"`
-	Flat     bool     `short:"f" long:"flat" description:"flatten objects" yaml:"flat"`
-	Vertical bool     `short:"v" long:"vertical" description:"vertical output" yaml:"vertical"`
-	Raw      bool     `short:"r" long:"raw" description:"raw output" yaml:"plain"`
-	NoPin    bool     `short:"n" long:"no-pin" description:"exclude pinning of fields" yaml:"noPin"`
+	Level         []string                     `short:"l" long:"level" env:"HZ_LEVEL" env-delim:"," description:"only output lines at this level" yaml:"level"`
+	Strict        bool                         `short:"s" long:"strict" env:"HZ_STRICT" description:"exclude non JSON output" yaml:"strict"`
+	Flat          bool                         `short:"f" long:"flat" env:"HZ_FLAT" description:"flatten objects and arrays" yaml:"flat"`
+	FlatDepth     int                          `long:"flat-depth" env:"HZ_FLAT_DEPTH" description:"maximum depth to flatten, 0 is unlimited" yaml:"flatDepth"`
+	FlatSep       string                       `long:"flat-sep" env:"HZ_FLAT_SEP" description:"separator for flattened keys (default: .)" yaml:"flatSep"`
+	Vertical      bool                         `short:"v" long:"vertical" env:"HZ_VERTICAL" description:"vertical output" yaml:"vertical"`
+	Raw           bool                         `short:"r" long:"raw" env:"HZ_RAW" description:"raw output" yaml:"raw"`
+	NoPin         bool                         `short:"n" long:"no-pin" env:"HZ_NO_PIN" description:"exclude pinning of fields" yaml:"noPin"`
+	MaxValueLen   int                          `long:"max-value-len" env:"HZ_MAX_VALUE_LEN" description:"truncate values longer than this many bytes" yaml:"maxValueLen"`
+	Wrap          bool                         `short:"w" long:"wrap" env:"HZ_WRAP" description:"wrap output to the terminal width" yaml:"wrap"`
+	Align         bool                         `short:"a" long:"align" env:"HZ_ALIGN" description:"align pinned fields into columns" yaml:"align"`
+	Columns       map[string]int               `long:"column" env:"HZ_COLUMNS" env-delim:"," description:"fix the width of an aligned pin" value-name:"PIN:WIDTH" yaml:"columns"`
+	HTTP          bool                         `long:"http" env:"HZ_HTTP" description:"pin HTTP request fields as an access log" yaml:"http"`
+	Humanize      bool                         `short:"H" long:"humanize" env:"HZ_HUMANIZE" description:"humanize durations, sizes and status codes" yaml:"humanize"`
+	Humanizers    map[string]string            `long:"humanize-field" env:"HZ_HUMANIZE_FIELDS" env-delim:"," description:"humanize a field as one of duration_ns, duration_us, duration_ms, duration_s, bytes, status" value-name:"FIELD:KIND" yaml:"humanizeFields"`
+	Summary       bool                         `long:"summary" env:"HZ_SUMMARY" description:"print summary statistics to stderr when the input ends" yaml:"summary"`
+	Schema        string                       `long:"schema" env:"HZ_SCHEMA" choice:"auto" choice:"ecs" choice:"otel" description:"map records of a log schema onto the pins, auto recognizes each record's schema" yaml:"schema"`
+	Rename        map[string]string            `long:"rename" env:"HZ_RENAME" env-delim:"," description:"rename field FROM to TO before pinning and filtering, nested fields joined with a dot" value-name:"FROM:TO" yaml:"rename"`
+	Values        map[string]map[string]string `no-flag:"true" description:"map the values of a field to others, set in the config file only" yaml:"values"`
+	Labels        map[string]string            `long:"label" env:"HZ_LABELS" env-delim:"," description:"set field NAME to TEMPLATE, with each {field} replaced by its value" value-name:"NAME:TEMPLATE" yaml:"labels"`
+	Script        string                       `long:"script" env:"HZ_SCRIPT" description:"transform records with the transform function of a Starlark script" value-name:"FILE" yaml:"script"`
+	ScriptTimeout time.Duration                `long:"script-timeout" env:"HZ_SCRIPT_TIMEOUT" description:"time budget of the script per record (default: 100ms)" value-name:"DURATION" yaml:"scriptTimeout"`
+	Select        string                       `long:"select" env:"HZ_SELECT" description:"reshape each record with a jq style query, e.g. '{msg: .message}'" value-name:"QUERY" yaml:"select"`
+	Plugins       []plugin.Config              `no-flag:"true" description:"external formatter plugins, set in the config file only" yaml:"plugins"`
+	Profile       string                       `short:"p" long:"profile" env:"HZ_PROFILE" description:"use the named profile from the config file" yaml:"-"`
+	Config        string                       `short:"c" long:"config" env:"HZ_CONFIG" description:"config file to use instead of the user and project config" yaml:"-"`
+	PrintConfig   bool                         `long:"print-config" description:"print the effective config and where each value came from" yaml:"-"`
 }
 
 func main() {
 	var cmd Cmd
-	// read in config file, if it exists
-	err := loadDefaults(&cmd)
-	if err != nil {
-		fmt.Fprint(os.Stderr, fmt.Errorf("WARN: unable to load config: %w", err), "\n")
-	}
+	// read in config files, if they exist
+	pre := parsePreArgs(os.Args[1:])
+	sources, cfgErr := loadDefaults(&cmd, pre.Config, pre.Profile)
 
 	// parse command line flags
 	parser := flags.NewParser(&cmd, flags.HelpFlag|flags.PassDoubleDash)
 	parser.Usage = "[FILE]"
-	filenames, err := parser.Parse()
+	parser.SubcommandsOptional = true
+	addCommands(parser, &cmd)
+	filenames, err := parser.ParseArgs(fileArgs(parser, os.Args[1:]))
 	if e, ok := err.(*flags.Error); ok && e.Type == flags.ErrHelp {
 		parser.WriteHelp(os.Stdout)
 		return
 	}
+	if parser.Active != nil {
+		// a subcommand was run
+		if err != nil {
+			fmt.Fprint(os.Stderr, err, "\n")
+			os.Exit(1)
+		}
+		return
+	}
 	if err != nil {
 		fmt.Fprint(os.Stderr, fmt.Errorf("unable to parse arguments: %w", err))
 	}
+	if cfgErr != nil {
+		fmt.Fprint(os.Stderr, fmt.Errorf("WARN: unable to load config: %w", cfgErr), "\n")
+	}
+
+	if cmd.PrintConfig {
+		sources.setFlags(parser)
+		if err := printConfig(os.Stdout, &cmd, sources); err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("unable to print config: %w", err), "\n")
+		}
+		return
+	}
 
 	bufSize := heron.DefaultBufSize
 	if cmd.Strict {
 		bufSize = 0
 	}
 
+	sink := pipeline.Pretty(writer.New(writerOptions(&cmd)...))
+
+	opts := []pipeline.Option{
+		pipeline.WithErrorHandler(func(err error) {
+			fmt.Fprint(os.Stderr, err, "\n")
+		}),
+	}
+
+	// summary counts every input record as decoded, ahead of the stages
+	// changing or dropping records and the level filter of the writer
+	var summary func()
+	if cmd.Summary {
+		s := stats.New()
+		var once sync.Once
+		summary = func() {
+			once.Do(func() {
+				fmt.Fprintln(os.Stderr)
+				_ = s.Write(os.Stderr)
+			})
+		}
+		opts = append(opts, pipeline.WithTransform(pipeline.TransformFunc(func(r pipeline.Record) pipeline.Record {
+			if r.IsText() {
+				s.Text(r.Text)
+			} else {
+				s.Record(r.Value)
+			}
+			return r
+		})))
+	}
+
+	norm, err := normalizers(&cmd)
+	if err != nil {
+		fmt.Fprint(os.Stderr, err, "\n")
+		os.Exit(1)
+	}
+	for _, e := range norm {
+		opts = append(opts, pipeline.WithExpander(e))
+	}
+	if cmd.Script != "" {
+		var sopts []script.Option
+		if cmd.ScriptTimeout > 0 {
+			sopts = append(sopts, script.WithTimeout(cmd.ScriptTimeout))
+		}
+		s, err := script.Load(cmd.Script, sopts...)
+		if err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("unable to load script: %w", err), "\n")
+			os.Exit(1)
+		}
+		opts = append(opts, pipeline.WithExpander(s))
+	}
+	if cmd.Select != "" {
+		q, err := query.Parse(cmd.Select)
+		if err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("unable to parse --select: %w", err), "\n")
+			os.Exit(1)
+		}
+		// filter on levels first, the query may leave the level out
+		opts = append(opts, pipeline.WithFilter(pipeline.Levels(cmd.Level...)), pipeline.WithExpander(q))
+	}
+	var plugins []*plugin.Plugin
+	for _, cfg := range cmd.Plugins {
+		pl, err := plugin.New(cfg, plugin.WithColor(!cmd.Raw))
+		if err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("unable to load plugin: %w", err), "\n")
+			os.Exit(1)
+		}
+		plugins = append(plugins, pl)
+		opts = append(opts, pipeline.WithExpander(pl))
+	}
+	closePlugins := func() {
+		for _, pl := range plugins {
+			_ = pl.Close()
+		}
+	}
+	p := pipeline.New(pipeline.JSON(heron.WithBufSize(bufSize)), sink, opts...)
+
+	gu.Terminator(func() int {
+		p.Flush()
+		closePlugins()
+		if summary != nil {
+			summary()
+		}
+		return 0
+	})
+
+	inputs := pipeline.Files(filenames...)
+	if len(inputs) == 0 {
+		inputs = []pipeline.Source{pipeline.Stdin()}
+	}
+	_ = p.Run(context.Background(), inputs...)
+	if summary != nil {
+		p.Flush()
+		summary()
+	}
+	closePlugins()
+}
+
+// normalizers returns the stages mapping records onto one vocabulary, as set
+// by the schema, rename, values and labels options, in that order.
+func normalizers(cmd *Cmd) ([]pipeline.Expander, error) {
+	var es []pipeline.Expander
+	if cmd.Schema != "" {
+		e, err := schema.New(cmd.Schema)
+		if err != nil {
+			return nil, err
+		}
+		es = append(es, e)
+	}
+	var ts []pipeline.Transform
+	if len(cmd.Rename) > 0 {
+		ts = append(ts, pipeline.Rename(cmd.Rename))
+	}
+	if len(cmd.Values) > 0 {
+		ts = append(ts, pipeline.MapValues(cmd.Values))
+	}
+	if len(cmd.Labels) > 0 {
+		ts = append(ts, pipeline.Labels(cmd.Labels))
+	}
+	for _, t := range ts {
+		t := t
+		es = append(es, pipeline.ExpanderFunc(func(r pipeline.Record) ([]pipeline.Record, error) {
+			return []pipeline.Record{t.Apply(r)}, nil
+		}))
+	}
+	return es, nil
+}
+
+// normalized returns fn called with each value the normalizers of cmd turn a
+// value into, for the commands reading records without a pipeline.
+func normalized(cmd *Cmd, fn func(any)) (func(any), error) {
+	es, err := normalizers(cmd)
+	if err != nil || len(es) == 0 {
+		return fn, err
+	}
+	var run func(i int, a any)
+	run = func(i int, a any) {
+		if i == len(es) {
+			fn(a)
+			return
+		}
+		records, _ := es[i].Expand(pipeline.Record{Value: a})
+		for _, r := range records {
+			run(i+1, r.Value)
+		}
+	}
+	return func(a any) {
+		run(0, a)
+	}, nil
+}
+
+// writerOptions returns the writer options for cmd.
+func writerOptions(cmd *Cmd) []writer.Option {
 	opts := []writer.Option{
 		writer.WithLevelFilters(cmd.Level),
 		writer.WithFlatten(cmd.Flat),
+		writer.WithFlattenDepth(cmd.FlatDepth),
 		writer.WithVertical(cmd.Vertical),
 		writer.WithColor(!cmd.Raw),
+		writer.WithMaxValueLen(cmd.MaxValueLen),
+		writer.WithAlign(cmd.Align),
 	}
 
-	if cmd.NoPin {
-		opts = append(opts, writer.WithPinOrder([]string{}))
+	for pin, width := range cmd.Columns {
+		opts = append(opts, writer.WithColumnWidth(pin, width))
 	}
 
-	w := writer.New(opts...)
-	// didnl is used to prevent double newlines since we want to ensure each JSON
-	// objects is on its own line but we want to preserve as much of the output
-	// as possible
-	didnl := true
-	h := heron.New(
-		heron.WithBufSize(bufSize),
-		heron.WithJSON(func(a any) {
-			s, _ := w.WriteAny(a)
-			if s > 0 {
-				didnl = true
-				w.Println()
-			}
-		}),
-		heron.WithBytes(func(b []byte) {
-			sb := string(b)
-			if sb == newline && didnl {
-				return
-			}
-			didnl = false
-			_, _ = w.Print(sb)
-		}),
-		heron.WithError(func(err error) {
-			fmt.Fprint(os.Stderr, fmt.Errorf("extractor error: %w", err))
-		}),
-	)
+	opts = append(opts, writer.WithHumanize(cmd.Humanize))
+	for field, kind := range cmd.Humanizers {
+		h, err := formatter.ParseHumanizer(kind, !cmd.Raw)
+		if err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("WARN: unable to humanize %q: %w", field, err), "\n")
+			continue
+		}
+		opts = append(opts, writer.WithHumanizer(field, h))
+	}
 
-	gu.Terminator(func() int {
-		h.Flush()
-		return 0
-	})
+	if cmd.Wrap {
+		opts = append(opts, writer.WithWrap(wrapWidth()))
+	}
 
-	if len(filenames) > 0 {
-		for _, filename := range filenames {
-			f, err := os.Open(filename)
-			if err != nil {
-				fmt.Fprint(os.Stderr, fmt.Errorf("unable to open %q: %w", filename, err))
-			}
-			h.Process(f)
+	if cmd.FlatSep != "" {
+		opts = append(opts, writer.WithFlattenSeparator(cmd.FlatSep))
+	}
+
+	if cmd.HTTP {
+		opts = append(opts, writer.WithPinBefore(writer.PinHTTP, writer.PinMessage))
+	}
+
+	for _, pl := range cmd.Plugins {
+		before := pl.Before
+		if before == "" {
+			before = writer.PinMessage
 		}
-		return
+		// the pin text is not a field of the record, hide it even unpinned
+		opts = append(opts,
+			writer.WithFormatter(pl.Name, plugin.Formatter(pl.Name)),
+			writer.WithPinBefore(pl.Name, before),
+			writer.WithExcludeKeysAppend([]string{plugin.Key(pl.Name)}),
+		)
 	}
 
-	h.Process(os.Stdin)
+	if cmd.NoPin {
+		opts = append(opts, writer.WithPinOrder([]string{}))
+	}
+	return opts
 }
 
-func loadDefaults(cfg *Cmd) error {
-	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
-		return nil
+// process calls fn with each file in turn, or stdin if there are none.
+func process(filenames []string, fn func(*os.File)) {
+	if len(filenames) == 0 {
+		fn(os.Stdin)
+		return
 	}
+	for _, filename := range filenames {
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Fprint(os.Stderr, fmt.Errorf("unable to open %q: %w", filename, err))
+			continue
+		}
+		fn(f)
+		f.Close()
+	}
+}
 
-	bytes, err := os.ReadFile(cfgPath)
-	if err != nil {
-		return fmt.Errorf("unable to read config file: %w", err)
+// wrapWidth returns the terminal width, falling back to $COLUMNS.
+func wrapWidth() int {
+	if w := termWidth(os.Stdout); w > 0 {
+		return w
+	}
+	if w, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil {
+		return w
 	}
-	return yaml.Unmarshal(bytes, &cfg)
+	return 0
 }
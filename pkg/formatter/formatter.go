@@ -13,6 +13,14 @@ type Formatter interface {
 	ExcludeKeys() []string
 }
 
+// RecordFormatter is implemented by formatters which hide only the fields a
+// record's pin used, rather than the fixed keys of ExcludeKeys.
+type RecordFormatter interface {
+	Formatter
+	// FormatRecord returns the pin of a record along with the fields it used.
+	FormatRecord(map[string]any) (string, []string)
+}
+
 // Fielder formats a key value pair
 type Fielder func(key string, value any) string
 
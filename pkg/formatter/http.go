@@ -0,0 +1,138 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	KeyMethod   = "method"
+	KeyPath     = "path"
+	KeyURL      = "url"
+	KeyStatus   = "status"
+	KeyDuration = "duration"
+	KeyRemoteIP = "remote_ip"
+)
+
+var _ Formatter = (*HTTP)(nil)
+
+var (
+	httpMethodKeys = []string{KeyMethod, "http.request.method", "http.method"}
+	httpPathKeys   = []string{KeyPath, KeyURL, "url.path", "url.original", "http.request.path", "http.target", "http.url"}
+	httpStatusKeys = []string{KeyStatus, "status_code", "statusCode", "http.response.status_code", "http.response.status", "http.status_code"}
+	httpRemoteKeys = []string{KeyRemoteIP, "remote_addr", "client.ip", "http.client_ip"}
+	httpDurations  = []struct {
+		key  string
+		unit time.Duration
+	}{
+		{KeyDuration, time.Millisecond},
+		{"duration_ms", time.Millisecond},
+		{"duration_ns", time.Nanosecond},
+		{"latency", time.Millisecond},
+		{"latency_ms", time.Millisecond},
+		{"http.response.duration", time.Millisecond},
+	}
+)
+
+// HTTP renders request/response records as an access log line, e.g.
+// GET /api/users 200 12ms. Both flat fields and the nested ECS/OpenTelemetry
+// http.request and http.response shapes are recognized. A record is a request
+// if it has a method along with a path or status.
+type HTTP struct {
+	color  bool
+	status Humanizer
+}
+
+var _ RecordFormatter = (*HTTP)(nil)
+
+func NewHTTP(color bool) Formatter {
+	return &HTTP{
+		color:  color,
+		status: Status(color),
+	}
+}
+
+func (f *HTTP) Format(m map[string]any) string {
+	s, _ := f.FormatRecord(m)
+	return s
+}
+
+// ExcludeKeys returns no keys, the fields of a request vary by record and are
+// returned by FormatRecord instead, so the same keys of other records show.
+func (f *HTTP) ExcludeKeys() []string {
+	return nil
+}
+
+// FormatRecord returns the access log line of m along with the fields it used,
+// nothing if m is not a request record.
+func (f *HTTP) FormatRecord(m map[string]any) (string, []string) {
+	mk, method, ok := first(m, httpMethodKeys)
+	if !ok {
+		return "", nil
+	}
+	pk, path, hasPath := first(m, httpPathKeys)
+	sk, status, hasStatus := first(m, httpStatusKeys)
+	if !hasPath && !hasStatus {
+		return "", nil
+	}
+
+	parts := []string{f.method(fmt.Sprintf("%v", method))}
+	keys := []string{mk}
+	if hasPath {
+		parts = append(parts, fmt.Sprintf("%v", path))
+		keys = append(keys, pk)
+	}
+	if hasStatus {
+		if s, ok := f.status(status); ok {
+			parts = append(parts, s)
+		} else {
+			parts = append(parts, fmt.Sprintf("%v", status))
+		}
+		keys = append(keys, sk)
+	}
+	for _, d := range httpDurations {
+		if v, ok := Lookup(m, d.key); ok {
+			if s, ok := Duration(d.unit)(v); ok {
+				parts = append(parts, s)
+			} else {
+				parts = append(parts, fmt.Sprintf("%v", v))
+			}
+			keys = append(keys, d.key)
+			break
+		}
+	}
+	if k, v, ok := first(m, httpRemoteKeys); ok {
+		parts = append(parts, Colorize(v, ColorDarkGray, f.color))
+		keys = append(keys, k)
+	}
+	return strings.Join(parts, " "), keys
+}
+
+func (f *HTTP) method(m string) string {
+	switch strings.ToUpper(m) {
+	case "GET", "HEAD", "OPTIONS":
+		return Colorize(m, ColorBlue, f.color)
+	case "POST":
+		return Colorize(m, ColorGreen, f.color)
+	case "PUT", "PATCH":
+		return Colorize(m, ColorYellow, f.color)
+	case "DELETE":
+		return Colorize(m, ColorRed, f.color)
+	}
+	return Colorize(m, ColorBold, f.color)
+}
+
+// first returns the first scalar value found at keys, along with its key.
+func first(m map[string]any, keys []string) (string, any, bool) {
+	for _, k := range keys {
+		if v, ok := Lookup(m, k); ok && v != nil {
+			switch v.(type) {
+			case map[string]any, []any:
+				continue
+			}
+			return k, v, true
+		}
+	}
+	return "", nil, false
+}
@@ -0,0 +1,90 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTP(t *testing.T) {
+	testcases := map[string]struct {
+		color  bool
+		msg    map[string]any
+		expect string
+	}{
+		"flat": {false, map[string]any{
+			"method":    "GET",
+			"path":      "/api/users",
+			"status":    jn(200),
+			"duration":  jn(12),
+			"remote_ip": "10.0.0.1",
+		}, "GET /api/users 200 12ms 10.0.0.1"},
+		"ecs": {false, map[string]any{
+			"http": map[string]any{
+				"request":  map[string]any{"method": "POST"},
+				"response": map[string]any{"status_code": jn(503)},
+			},
+			"url": map[string]any{"path": "/login", "domain": "localhost"},
+		}, "POST /login 503"},
+		"otel": {false, map[string]any{
+			"http.method":      "DELETE",
+			"http.target":      "/items/1",
+			"http.status_code": jn(404),
+		}, "DELETE /items/1 404"},
+		"duration-string": {false, map[string]any{"method": "GET", "url": "/", "duration": "1.5s"}, "GET / 1.5s"},
+		"duration-ns":     {false, map[string]any{"method": "GET", "url": "/", "duration_ns": jn(1500)}, "GET / 1.5µs"},
+		"status-string":   {false, map[string]any{"method": "GET", "url": "/", "status": "ok"}, "GET / ok"},
+		"not-request":     {false, map[string]any{"status": jn(200), "duration": jn(5)}, ""},
+		"path-only":       {false, map[string]any{"msg": "opened", "path": "/tmp/x"}, ""},
+		"method-only":     {false, map[string]any{"method": "UserService.Get"}, ""},
+		"method-status":   {false, map[string]any{"method": "GET", "status": jn(204)}, "GET 204"},
+		"color": {true, map[string]any{
+			"method": "GET",
+			"path":   "/",
+			"status": jn(200),
+		}, "\x1b[34mGET\x1b[0m / \x1b[32m200\x1b[0m"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			f := formatter.NewHTTP(tc.color)
+			require.Equal(t, tc.expect, f.Format(tc.msg))
+		})
+	}
+}
+
+func TestHTTP_FormatRecord(t *testing.T) {
+	testcases := map[string]struct {
+		msg    map[string]any
+		expect []string
+	}{
+		"flat": {map[string]any{
+			"method":   "GET",
+			"path":     "/api/users",
+			"status":   jn(200),
+			"duration": jn(12),
+			"latency":  jn(10),
+			"url":      "/other",
+		}, []string{"method", "path", "status", "duration"}},
+		"ecs": {map[string]any{
+			"http": map[string]any{
+				"request":  map[string]any{"method": "POST"},
+				"response": map[string]any{"status_code": jn(503)},
+			},
+			"url":    map[string]any{"path": "/login", "domain": "localhost"},
+			"client": map[string]any{"ip": "10.0.0.1"},
+		}, []string{"http.request.method", "url.path", "http.response.status_code", "client.ip"}},
+		"not-request": {map[string]any{"status": jn(200), "duration": jn(5)}, nil},
+		"path-only":   {map[string]any{"msg": "opened", "path": "/tmp/x"}, nil},
+	}
+	f := formatter.NewHTTP(false)
+	require.Empty(t, f.ExcludeKeys())
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			rf, ok := f.(formatter.RecordFormatter)
+			require.True(t, ok)
+			_, keys := rf.FormatRecord(tc.msg)
+			require.Equal(t, tc.expect, keys)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package formatter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	HumanizeDurationNs = "duration_ns"
+	HumanizeDurationUs = "duration_us"
+	HumanizeDurationMs = "duration_ms"
+	HumanizeDurationS  = "duration_s"
+	HumanizeBytes      = "bytes"
+	HumanizeStatus     = "status"
+)
+
+// Humanizer renders a value in a human friendly form, returning false when
+// the value is not applicable.
+type Humanizer func(value any) (string, bool)
+
+// Humanizers maps field names to humanizers. Names starting with "*" match
+// any field with that suffix.
+type Humanizers map[string]Humanizer
+
+// DefaultHumanizers returns humanizers for common field naming conventions.
+func DefaultHumanizers(color bool) Humanizers {
+	return Humanizers{
+		"*_ns":        Duration(time.Nanosecond),
+		"*_us":        Duration(time.Microsecond),
+		"*_ms":        Duration(time.Millisecond),
+		"*_sec":       Duration(time.Second),
+		"*_bytes":     Bytes(),
+		"bytes":       Bytes(),
+		"size":        Bytes(),
+		"status":      Status(color),
+		"status_code": Status(color),
+		"statusCode":  Status(color),
+	}
+}
+
+// ParseHumanizer returns the humanizer for the given kind, one of
+// duration_ns, duration_us, duration_ms, duration_s, bytes or status.
+func ParseHumanizer(kind string, color bool) (Humanizer, error) {
+	switch kind {
+	case HumanizeDurationNs:
+		return Duration(time.Nanosecond), nil
+	case HumanizeDurationUs:
+		return Duration(time.Microsecond), nil
+	case HumanizeDurationMs:
+		return Duration(time.Millisecond), nil
+	case HumanizeDurationS:
+		return Duration(time.Second), nil
+	case HumanizeBytes:
+		return Bytes(), nil
+	case HumanizeStatus:
+		return Status(color), nil
+	}
+	return nil, fmt.Errorf("unknown humanizer %q", kind)
+}
+
+// Humanize decorates fn, rendering the values of fields matched by h with
+// their humanizer. Nested keys also match on their last segment.
+func Humanize(formatKey Stringer, h Humanizers, fn Fielder) Fielder {
+	return func(key string, value any) string {
+		if hfn := h.match(key); hfn != nil {
+			if s, ok := hfn(value); ok {
+				return formatKey(key) + s
+			}
+		}
+		return fn(key, value)
+	}
+}
+
+func (h Humanizers) match(key string) Humanizer {
+	if fn, ok := h[key]; ok {
+		return fn
+	}
+	if i := strings.LastIndex(key, PathSep); i >= 0 {
+		if fn, ok := h[key[i+1:]]; ok {
+			return fn
+		}
+	}
+	var match Humanizer
+	var n int
+	for k, fn := range h {
+		if strings.HasPrefix(k, "*") && strings.HasSuffix(key, k[1:]) && len(k) > n {
+			match, n = fn, len(k)
+		}
+	}
+	return match
+}
+
+// Duration renders numbers of unit as a duration, e.g. 1.23s.
+func Duration(unit time.Duration) Humanizer {
+	return func(value any) (string, bool) {
+		f, ok := number(value)
+		if !ok {
+			return "", false
+		}
+		return humanDuration(f * float64(unit)), true
+	}
+}
+
+// Bytes renders numbers as a byte size, e.g. 4.5 MiB.
+func Bytes() Humanizer {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	return func(value any) (string, bool) {
+		f, ok := number(value)
+		if !ok {
+			return "", false
+		}
+		i := 0
+		for math.Abs(f) >= 1024 && i < len(units)-1 {
+			f /= 1024
+			i++
+		}
+		return round(f) + " " + units[i], true
+	}
+}
+
+// Status colors HTTP status codes by class.
+func Status(color bool) Humanizer {
+	return func(value any) (string, bool) {
+		f, ok := number(value)
+		if !ok || f < 100 || f > 599 || f != math.Trunc(f) {
+			return "", false
+		}
+		s := strconv.Itoa(int(f))
+		switch {
+		case f >= 500:
+			return Boldrize(s, ColorRed, color), true
+		case f >= 400:
+			return Colorize(s, ColorYellow, color), true
+		case f >= 300:
+			return Colorize(s, ColorCyan, color), true
+		case f >= 200:
+			return Colorize(s, ColorGreen, color), true
+		}
+		return s, true
+	}
+}
+
+func humanDuration(ns float64) string {
+	d := time.Duration(ns)
+	if d >= time.Minute || d <= -time.Minute {
+		return d.Round(time.Second).String()
+	}
+	units := []struct {
+		name string
+		size float64
+	}{
+		{"s", float64(time.Second)},
+		{"ms", float64(time.Millisecond)},
+		{"µs", float64(time.Microsecond)},
+	}
+	for _, u := range units {
+		if math.Abs(ns) >= u.size {
+			return round(ns/u.size) + u.name
+		}
+	}
+	return round(ns) + "ns"
+}
+
+// round formats f with three significant digits, dropping trailing zeros.
+func round(f float64) string {
+	prec := 0
+	switch a := math.Abs(f); {
+	case a < 10:
+		prec = 2
+	case a < 100:
+		prec = 1
+	}
+	s := strconv.FormatFloat(f, 'f', prec, 64)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
+
+func number(value any) (float64, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,54 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHumanize(t *testing.T) {
+	testcases := map[string]struct {
+		color  bool
+		key    string
+		value  any
+		expect string
+	}{
+		"ns":           {false, "latency_ns", jn(1234567), "latency_ns=1.23ms"},
+		"us":           {false, "took_us", jn(15), "took_us=15µs"},
+		"ms":           {false, "elapsed_ms", jn(1230), "elapsed_ms=1.23s"},
+		"ms-float":     {false, "elapsed_ms", jn("0.5"), "elapsed_ms=500µs"},
+		"sec":          {false, "uptime_sec", jn(3725), "uptime_sec=1h2m5s"},
+		"sub-ns":       {false, "latency_ns", jn(12), "latency_ns=12ns"},
+		"bytes":        {false, "bytes", jn(4718592), "bytes=4.5 MiB"},
+		"bytes-small":  {false, "resp_bytes", jn(512), "resp_bytes=512 B"},
+		"bytes-kib":    {false, "size", jn(123456), "size=121 KiB"},
+		"status":       {false, "status", jn(200), "status=200"},
+		"status-color": {true, "status", jn(200), "status=\x1b[32m200\x1b[0m"},
+		"status-4xx":   {true, "statusCode", jn(404), "statusCode=\x1b[33m404\x1b[0m"},
+		"status-5xx":   {true, "status_code", jn(503), "status_code=\x1b[1m\x1b[31m503\x1b[0m\x1b[0m"},
+		"status-bad":   {false, "status", "ok", "status=ok"},
+		"nested":       {false, "http.response.status", jn(302), "http.response.status=302"},
+		"not-number":   {false, "latency_ns", "fast", "latency_ns=fast"},
+		"no-match":     {false, "count", jn(1234567), "count=1234567"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			f := formatter.Humanize(formatKey, formatter.DefaultHumanizers(tc.color), formatter.Map(formatKey))
+			require.Equal(t, tc.expect, f(tc.key, tc.value))
+		})
+	}
+}
+
+func TestParseHumanizer(t *testing.T) {
+	for _, kind := range []string{"duration_ns", "duration_us", "duration_ms", "duration_s", "bytes", "status"} {
+		t.Run(kind, func(t *testing.T) {
+			h, err := formatter.ParseHumanizer(kind, false)
+			require.NoError(t, err)
+			require.NotNil(t, h)
+		})
+	}
+
+	_, err := formatter.ParseHumanizer("unknown", false)
+	require.Error(t, err)
+}
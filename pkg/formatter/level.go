@@ -114,6 +114,20 @@ func GetLevels(m map[string]any) map[string]string {
 	return levels
 }
 
+// MatchLevels reports whether every level of m is one of include. Records
+// without a level always match, as does an empty include.
+func MatchLevels(m map[string]any, include []string) bool {
+	if len(include) == 0 {
+		return true
+	}
+	for _, l := range GetLevels(m) {
+		if !gu.Includes(include, l) {
+			return false
+		}
+	}
+	return true
+}
+
 func getLevel(i any) string {
 	if i == nil {
 		return ""
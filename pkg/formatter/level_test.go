@@ -62,6 +62,26 @@ func TestLevel_ExcludeKeys(t *testing.T) {
 	require.Equal(t, []string{"level", "log.level"}, f.ExcludeKeys())
 }
 
+func TestMatchLevels(t *testing.T) {
+	testcases := map[string]struct {
+		msg     map[string]any
+		include []string
+		expect  bool
+	}{
+		"no-filter": {ml("info"), nil, true},
+		"match":     {ml("info"), []string{"info", "warn"}, true},
+		"no-match":  {ml("debug"), []string{"info", "warn"}, false},
+		"number":    {ml(40), []string{"warn"}, true},
+		"mismatch":  {map[string]any{"level": "info", "log": map[string]any{"level": "debug"}}, []string{"info"}, false},
+		"no-level":  {map[string]any{"msg": "hi"}, []string{"info"}, true},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, formatter.MatchLevels(tc.msg, tc.include))
+		})
+	}
+}
+
 func ml(level any) map[string]any {
 	if l, ok := level.(int); ok {
 		level = jn(l)
@@ -0,0 +1,46 @@
+package formatter
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	PathSep = "."
+)
+
+// Lookup resolves a dotted path (e.g. "request.headers.0") against m. Keys
+// which themselves contain dots are matched before descending.
+func Lookup(m map[string]any, path string) (any, bool) {
+	return lookup(m, path)
+}
+
+func lookup(v any, path string) (any, bool) {
+	switch vv := v.(type) {
+	case map[string]any:
+		if i, ok := vv[path]; ok {
+			return i, true
+		}
+		for i := 0; i < len(path); i++ {
+			if path[i] != PathSep[0] {
+				continue
+			}
+			if next, ok := vv[path[:i]]; ok {
+				if r, ok := lookup(next, path[i+1:]); ok {
+					return r, true
+				}
+			}
+		}
+	case []any:
+		head, rest, more := strings.Cut(path, PathSep)
+		idx, err := strconv.Atoi(head)
+		if err != nil || idx < 0 || idx >= len(vv) {
+			return nil, false
+		}
+		if !more {
+			return vv[idx], true
+		}
+		return lookup(vv[idx], rest)
+	}
+	return nil, false
+}
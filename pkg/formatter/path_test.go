@@ -0,0 +1,44 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	data := map[string]any{
+		"key": "value",
+		"request": map[string]any{
+			"url":     "foo",
+			"headers": []any{"a", map[string]any{"b": "c"}},
+		},
+		"log.level": "info",
+		"log": map[string]any{
+			"level": "warn",
+		},
+	}
+
+	testcases := map[string]struct {
+		path   string
+		expect any
+		ok     bool
+	}{
+		"key":          {"key", "value", true},
+		"nested":       {"request.url", "foo", true},
+		"index":        {"request.headers.0", "a", true},
+		"index-nested": {"request.headers.1.b", "c", true},
+		"literal-dot":  {"log.level", "info", true},
+		"missing":      {"request.body", nil, false},
+		"out-of-range": {"request.headers.5", nil, false},
+		"not-index":    {"request.headers.x", nil, false},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			v, ok := formatter.Lookup(data, tc.path)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expect, v)
+		})
+	}
+}
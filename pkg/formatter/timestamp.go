@@ -2,6 +2,7 @@ package formatter
 
 import (
 	"encoding/json"
+	"math"
 	"time"
 
 	"github.com/dcilke/gu"
@@ -69,32 +70,52 @@ func (f *Timestamp) ExcludeKeys() []string {
 }
 
 func (f *Timestamp) getTime(i any) string {
-	t := ""
+	if ts, ok := ParseTime(i); ok {
+		return ts.Format(f.timeFormat)
+	}
+	switch tt := i.(type) {
+	case string:
+		return tt
+	case json.Number:
+		return tt.String()
+	}
+	return ""
+}
+
+// ParseTime returns the time of a timestamp value, an RFC3339 string or a unix
+// epoch number. Integer epochs are read as seconds, milliseconds, microseconds
+// or nanoseconds by their magnitude, fractional epochs as seconds.
+func ParseTime(i any) (time.Time, bool) {
 	switch tt := i.(type) {
 	case string:
 		ts, err := time.Parse(TimeFormat, tt)
-		if err != nil {
-			t = tt
-		} else {
-			t = ts.Format(f.timeFormat)
-		}
+		return ts, err == nil
 	case json.Number:
-		i, err := tt.Int64()
-		if err != nil {
-			t = tt.String()
-		} else {
-			var sec, nsec int64 = i, 0
-			switch TimeFormat {
-			case TimeFormatUnixMs:
-				nsec = int64(time.Duration(i) * time.Millisecond)
-				sec = 0
-			case TimeFormatUnixMicro:
-				nsec = int64(time.Duration(i) * time.Microsecond)
-				sec = 0
-			}
-			ts := time.Unix(sec, nsec).UTC()
-			t = ts.Format(f.timeFormat)
+		if n, err := tt.Int64(); err == nil {
+			return epoch(n), true
+		}
+		if f, err := tt.Float64(); err == nil && !math.IsInf(f, 0) && math.Abs(f) < 1e11 {
+			sec, frac := math.Modf(f)
+			return time.Unix(int64(sec), int64(frac*1e9)).UTC(), true
 		}
 	}
-	return t
+	return time.Time{}, false
+}
+
+// epoch returns the time of a unix epoch in the unit its magnitude suggests,
+// seconds until the year 5138.
+func epoch(n int64) time.Time {
+	abs := n
+	if abs < 0 {
+		abs = -abs
+	}
+	switch {
+	case abs < 1e11:
+		return time.Unix(n, 0).UTC()
+	case abs < 1e14:
+		return time.UnixMilli(n).UTC()
+	case abs < 1e17:
+		return time.UnixMicro(n).UTC()
+	}
+	return time.Unix(0, n).UTC()
 }
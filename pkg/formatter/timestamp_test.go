@@ -29,6 +29,10 @@ func TestTimestamp(t *testing.T) {
 		"time-color":          {true, map[string]any{"time": ts}, cexpect},
 		"unknown-time":        {false, map[string]any{"time": "unknown"}, "unknown"},
 		"number-time":         {false, map[string]any{"time": jn(1111)}, "00:18:31"},
+		"millis-time":         {false, map[string]any{"time": jn(1659530065142)}, expect},
+		"micros-time":         {false, map[string]any{"time": jn(1659530065142900)}, expect},
+		"nanos-time":          {false, map[string]any{"time": jn(1659530065142900417)}, expect},
+		"float-time":          {false, map[string]any{"time": jn("1659530065.1429")}, expect},
 		"invalid-time":        {false, map[string]any{"time": jn("unknown")}, "unknown"},
 	}
 	for name, tc := range testcases {
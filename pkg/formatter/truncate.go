@@ -0,0 +1,44 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	Ellipsis = "…"
+)
+
+// Truncate decorates fn, shortening values longer than n bytes to n bytes
+// followed by an ellipsis and the original size.
+func Truncate(n int, color bool, fn Fielder) Fielder {
+	return func(key string, value any) string {
+		if s, ok := value.(string); ok {
+			if len(s) <= n {
+				return fn(key, s)
+			}
+			return fn(key, cut(s, n)) + truncated(len(s), color)
+		}
+
+		ret := fn(key, value)
+		prefix := fn(key, "")
+		if !strings.HasPrefix(ret, prefix) || len(ret)-len(prefix) <= n {
+			return ret
+		}
+		v := ret[len(prefix):]
+		return prefix + cut(v, n) + truncated(len(v), color)
+	}
+}
+
+// cut shortens s to at most n bytes without splitting a rune.
+func cut(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func truncated(size int, color bool) string {
+	return Colorize(fmt.Sprintf("%s(%d bytes)", Ellipsis, size), ColorDarkGray, color)
+}
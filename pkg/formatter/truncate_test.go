@@ -0,0 +1,32 @@
+package formatter_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncate(t *testing.T) {
+	testcases := map[string]struct {
+		color  bool
+		value  any
+		expect string
+	}{
+		"short":     {false, "value", "key=value"},
+		"exact":     {false, "0123456789", "key=0123456789"},
+		"long":      {false, "0123456789abc", "key=0123456789…(13 bytes)"},
+		"quoted":    {false, "0123 56789 bc", "key=\"0123 56789\"…(13 bytes)"},
+		"rune":      {false, "012345678é", "key=012345678…(11 bytes)"},
+		"number":    {false, jn("12345678901234"), "key=1234567890…(14 bytes)"},
+		"object":    {false, map[string]any{"foo": "barbazqux"}, "key={\"foo\":\"ba…(19 bytes)"},
+		"color":     {true, "0123456789abc", "key=0123456789\x1b[90m…(13 bytes)\x1b[0m"},
+		"short-obj": {false, map[string]any{"a": "b"}, "key={\"a\":\"b\"}"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			f := formatter.Truncate(10, tc.color, formatter.Map(formatKey))
+			require.Equal(t, tc.expect, f("key", tc.value))
+		})
+	}
+}
@@ -0,0 +1,255 @@
+//go:build go1.21
+
+// Package handler provides a log/slog Handler which renders records the way
+// the hz command line does.
+package handler
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/writer"
+)
+
+// Ensure we are adhering to the slog.Handler interface.
+var _ slog.Handler = (*Handler)(nil)
+
+// Handler is a slog.Handler writing records through a writer.Writer, so they
+// get the same pins, formatters and colors as the hz command line. Records are
+// built as maps directly, without encoding them as JSON.
+type Handler struct {
+	opts slog.HandlerOptions
+	w    writer.Writer
+
+	// attrs holds the attributes added by WithAttrs, nested under their groups
+	attrs map[string]any
+	// groups are the open groups, in order
+	groups []string
+}
+
+// New returns a Handler writing to out. opts may be nil, wopts configure the
+// writer as they do for writer.New.
+func New(out io.Writer, opts *slog.HandlerOptions, wopts ...writer.Option) *Handler {
+	h := &Handler{
+		w:     writer.New(append(wopts, writer.WithOut(out))...),
+		attrs: map[string]any{},
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, l slog.Level) bool {
+	min := slog.LevelInfo
+	if h.opts.Level != nil {
+		min = h.opts.Level.Level()
+	}
+	return l >= min
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	m := make(map[string]any, 4+r.NumAttrs())
+	// ReplaceAttr sees the built in attributes with their slog keys
+	builtin := func(slogKey, key string, v slog.Value) {
+		a := h.replace(nil, slog.Attr{Key: slogKey, Value: v})
+		a.Value = a.Value.Resolve()
+		if a.Key == "" {
+			return
+		}
+		if a.Key == slogKey {
+			a.Key = key
+		}
+		switch vv := a.Value.Any().(type) {
+		case slog.Level:
+			m[a.Key] = Level(vv)
+		case *slog.Source:
+			m[a.Key] = vv.File + ":" + strconv.Itoa(vv.Line)
+		default:
+			m[a.Key] = value(a.Value)
+		}
+	}
+	if !r.Time.IsZero() {
+		builtin(slog.TimeKey, formatter.KeyTime, slog.TimeValue(r.Time))
+	}
+	builtin(slog.LevelKey, formatter.KeyLevel, slog.AnyValue(r.Level))
+	if h.opts.AddSource && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		builtin(slog.SourceKey, formatter.KeyCaller, slog.AnyValue(&slog.Source{Function: f.Function, File: f.File, Line: f.Line}))
+	}
+	builtin(slog.MessageKey, formatter.KeyMessage, slog.StringValue(r.Message))
+
+	for k, v := range clone(h.attrs) {
+		m[k] = v
+	}
+	if r.NumAttrs() > 0 {
+		g := group(m, h.groups)
+		r.Attrs(func(a slog.Attr) bool {
+			h.add(g, h.groups, a)
+			return true
+		})
+		prune(m, h.groups)
+	}
+
+	_, err := h.w.WriteLine(m)
+	return err
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.attrs = clone(h.attrs)
+	g := group(h2.attrs, h.groups)
+	for _, a := range attrs {
+		h2.add(g, h.groups, a)
+	}
+	prune(h2.attrs, h.groups)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return &h2
+}
+
+// add sets the attribute in m, the map of the groups.
+func (h *Handler) add(m map[string]any, groups []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() != slog.KindGroup {
+		a = h.replace(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		m[a.Key] = value(a.Value)
+		return
+	}
+
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	// a group without a key is inlined
+	g := m
+	if a.Key != "" {
+		sub, ok := m[a.Key].(map[string]any)
+		if !ok {
+			sub = make(map[string]any, len(attrs))
+		}
+		g = sub
+		groups = append(groups[:len(groups):len(groups)], a.Key)
+	}
+	for _, ga := range attrs {
+		h.add(g, groups, ga)
+	}
+	if a.Key != "" && len(g) > 0 {
+		m[a.Key] = g
+	}
+}
+
+func (h *Handler) replace(groups []string, a slog.Attr) slog.Attr {
+	if h.opts.ReplaceAttr == nil {
+		return a
+	}
+	return h.opts.ReplaceAttr(groups, a)
+}
+
+// Level returns the hz level name of l. Levels between the slog levels map to
+// the level below, and anything under debug is trace.
+func Level(l slog.Level) string {
+	switch {
+	case l < slog.LevelDebug:
+		return formatter.LevelTraceStr
+	case l < slog.LevelInfo:
+		return formatter.LevelDebugStr
+	case l < slog.LevelWarn:
+		return formatter.LevelInfoStr
+	case l < slog.LevelError:
+		return formatter.LevelWarnStr
+	}
+	return formatter.LevelErrorStr
+}
+
+// value returns v as the writer expects to find it in a decoded record.
+func value(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindInt64:
+		return v.Int64()
+	case slog.KindUint64:
+		return v.Uint64()
+	case slog.KindFloat64:
+		return v.Float64()
+	case slog.KindBool:
+		return v.Bool()
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindGroup:
+		m := make(map[string]any, len(v.Group()))
+		for _, a := range v.Group() {
+			m[a.Key] = value(a.Value.Resolve())
+		}
+		return m
+	}
+	if err, ok := v.Any().(error); ok {
+		return err.Error()
+	}
+	return v.Any()
+}
+
+// group returns the map of the nested groups in m, creating them as needed.
+func group(m map[string]any, groups []string) map[string]any {
+	for _, g := range groups {
+		sub, ok := m[g].(map[string]any)
+		if !ok {
+			sub = make(map[string]any)
+			m[g] = sub
+		}
+		m = sub
+	}
+	return m
+}
+
+// prune removes the nested groups of m which were left empty.
+func prune(m map[string]any, groups []string) {
+	if len(groups) == 0 {
+		return
+	}
+	sub, ok := m[groups[0]].(map[string]any)
+	if !ok {
+		return
+	}
+	prune(sub, groups[1:])
+	if len(sub) == 0 {
+		delete(m, groups[0])
+	}
+}
+
+// clone copies the maps of m so groups can be added to without changing m.
+func clone(m map[string]any) map[string]any {
+	c := make(map[string]any, len(m))
+	for k, v := range m {
+		if sub, ok := v.(map[string]any); ok {
+			v = clone(sub)
+		}
+		c[k] = v
+	}
+	return c
+}
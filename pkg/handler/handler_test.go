@@ -0,0 +1,167 @@
+//go:build go1.21
+
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/handler"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/stretchr/testify/require"
+)
+
+var at = time.Date(2022, 8, 3, 12, 34, 25, 0, time.UTC)
+
+type token string
+
+func (token) LogValue() slog.Value {
+	return slog.StringValue("<redacted>")
+}
+
+func record(level slog.Level, msg string, attrs ...slog.Attr) slog.Record {
+	r := slog.NewRecord(at, level, msg, 0)
+	r.AddAttrs(attrs...)
+	return r
+}
+
+func TestHandler(t *testing.T) {
+	testcases := map[string]struct {
+		handler func(slog.Handler) slog.Handler
+		record  slog.Record
+	}{
+		"message": {nil, record(slog.LevelInfo, "hello")},
+		"attrs": {nil, record(slog.LevelWarn, "slow request",
+			slog.String("path", "/api/users"),
+			slog.Int("status", 200),
+			slog.Float64("ratio", 0.5),
+			slog.Bool("cached", false),
+			slog.Duration("elapsed", 1532*time.Millisecond),
+			slog.Time("at", at),
+		)},
+		"error":   {nil, record(slog.LevelError, "query failed", slog.Any("error", errors.New("connection refused")))},
+		"group":   {nil, record(slog.LevelInfo, "request", slog.Group("req", slog.String("method", "GET"), slog.String("url", "/")))},
+		"inline":  {nil, record(slog.LevelInfo, "request", slog.Group("", slog.String("method", "GET")))},
+		"empty":   {nil, record(slog.LevelInfo, "request", slog.Group("req"), slog.Attr{})},
+		"valuer":  {nil, record(slog.LevelInfo, "login", slog.Any("token", token("secret")))},
+		"no-time": {nil, slog.NewRecord(time.Time{}, slog.LevelInfo, "no time", 0)},
+		"trace":   {nil, record(slog.LevelDebug-4, "deep")},
+		"between": {nil, record(slog.LevelInfo+2, "info and a bit")},
+		"with-attrs": {
+			func(h slog.Handler) slog.Handler { return h.WithAttrs([]slog.Attr{slog.String("service", "api")}) },
+			record(slog.LevelInfo, "started", slog.Int("port", 8080)),
+		},
+		"with-group": {
+			func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("service", "api")}).
+					WithGroup("req").
+					WithAttrs([]slog.Attr{slog.String("id", "abc")}).
+					WithGroup("user")
+			},
+			record(slog.LevelInfo, "request", slog.Int("id", 42)),
+		},
+		"with-group-empty": {
+			func(h slog.Handler) slog.Handler { return h.WithGroup("req") },
+			record(slog.LevelInfo, "no attrs"),
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			var h slog.Handler = handler.New(buf, &slog.HandlerOptions{Level: slog.LevelDebug - 4}, writer.WithColor(false))
+			if tc.handler != nil {
+				h = tc.handler(h)
+			}
+			require.NoError(t, h.Handle(context.Background(), tc.record))
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestHandler_Color(t *testing.T) {
+	buf := new(bytes.Buffer)
+	h := handler.New(buf, nil, writer.WithColor(true))
+	require.NoError(t, h.Handle(context.Background(), record(slog.LevelError, "query failed", slog.String("error", "connection refused"))))
+	golden.Assert(t, buf.Bytes())
+}
+
+func TestHandler_Enabled(t *testing.T) {
+	ctx := context.Background()
+	h := handler.New(nil, nil)
+	require.False(t, h.Enabled(ctx, slog.LevelDebug))
+	require.True(t, h.Enabled(ctx, slog.LevelInfo))
+
+	h = handler.New(nil, &slog.HandlerOptions{Level: slog.LevelError})
+	require.False(t, h.Enabled(ctx, slog.LevelWarn))
+	require.True(t, h.Enabled(ctx, slog.LevelError))
+}
+
+func TestHandler_Source(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := slog.New(handler.New(buf, &slog.HandlerOptions{AddSource: true}, writer.WithColor(false)))
+	logger.Info("with source")
+	require.Contains(t, buf.String(), "handler_test.go:")
+	require.Contains(t, buf.String(), "with source")
+}
+
+func TestHandler_ReplaceAttr(t *testing.T) {
+	buf := new(bytes.Buffer)
+	h := handler.New(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			switch {
+			case a.Key == slog.TimeKey && len(groups) == 0:
+				return slog.Attr{}
+			case a.Key == "password":
+				return slog.String(a.Key, "***")
+			case a.Key == "id" && len(groups) > 0:
+				return slog.String(strings.Join(groups, ".")+".id", a.Value.String())
+			}
+			return a
+		},
+	}, writer.WithColor(false))
+	r := record(slog.LevelInfo, "login", slog.String("password", "hunter2"), slog.Group("user", slog.Int("id", 7)))
+	require.NoError(t, h.Handle(context.Background(), r))
+	golden.Assert(t, buf.Bytes())
+}
+
+func TestHandler_Concurrent(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := slog.New(handler.New(buf, nil, writer.WithColor(false)))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			l := logger.With("worker", i).WithGroup("job")
+			for j := 0; j < 50; j++ {
+				l.Info("done", "n", j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 400)
+}
+
+func TestLevel(t *testing.T) {
+	testcases := map[slog.Level]string{
+		slog.LevelDebug - 1: "trace",
+		slog.LevelDebug:     "debug",
+		slog.LevelInfo:      "info",
+		slog.LevelInfo + 1:  "info",
+		slog.LevelWarn:      "warn",
+		slog.LevelError:     "error",
+		slog.LevelError + 4: "error",
+	}
+	for l, expect := range testcases {
+		t.Run(l.String(), func(t *testing.T) {
+			require.Equal(t, expect, handler.Level(l))
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package pager_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pager"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeys(t *testing.T) {
+	testcases := map[string]struct {
+		input  string
+		expect []string
+	}{
+		"chars":     {"jk/", []string{"j", "k", "/"}},
+		"arrows":    {"\x1b[A\x1b[B\x1bOA", []string{pager.KeyUp, pager.KeyDown, pager.KeyUp}},
+		"pages":     {"\x1b[5~\x1b[6~", []string{pager.KeyPageUp, pager.KeyPageDown}},
+		"home-end":  {"\x1b[H\x1b[4~", []string{pager.KeyHome, pager.KeyEnd}},
+		"escape":    {"\x1b", []string{pager.KeyEscape}},
+		"unknown":   {"\x1b[15~q", []string{"q"}},
+		"control":   {"\r\x7f\x03", []string{pager.KeyEnter, pager.KeyBackspace, pager.KeyCtrlC}},
+		"unicode":   {"é", []string{"é"}},
+		"ignored":   {"\x01a", []string{"a"}},
+		"truncated": {"\x1b[", nil},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, pager.ParseKeys([]byte(tc.input)))
+		})
+	}
+}
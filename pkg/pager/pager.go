@@ -0,0 +1,604 @@
+package pager
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/dcilke/gu"
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/dcilke/hz/pkg/writer"
+)
+
+const (
+	// DefaultSize is the number of records held by the pager.
+	DefaultSize = 10000
+
+	tabWidth = 4
+	reset    = "\x1b[0m"
+	reverse  = "\x1b[7m"
+)
+
+// Levels are toggled by the number keys 1 to 7, in order.
+var Levels = []string{
+	formatter.LevelTraceStr,
+	formatter.LevelDebugStr,
+	formatter.LevelInfoStr,
+	formatter.LevelWarnStr,
+	formatter.LevelErrorStr,
+	formatter.LevelFatalStr,
+	formatter.LevelPanicStr,
+}
+
+var help = []string{
+	"hz view keys",
+	"",
+	"  j, down        next record",
+	"  k, up          previous record",
+	"  space, pgdown  next page",
+	"  b, pgup        previous page",
+	"  g, home        first record",
+	"  G, end         last record, following new records",
+	"  /              search",
+	"  n, N           next, previous match",
+	"  enter          expand or collapse the record",
+	"  1-7            toggle trace, debug, info, warn, error, fatal, panic",
+	"  0              show every level",
+	"  -              hide a field",
+	"  +              show every field",
+	"  f              freeze or unfreeze the stream",
+	"  ?, esc         close this help",
+	"  q              quit",
+}
+
+type mode int
+
+const (
+	modeNormal mode = iota
+	modeSearch
+	modeHide
+)
+
+// entry is a record, or a line of non-JSON input.
+type entry struct {
+	value    any
+	text     string
+	level    string
+	search   string
+	expanded bool
+
+	// lines caches the rendering for generation gen
+	lines []string
+	gen   int
+}
+
+// Pager is the state of the interactive viewer: the records held, what is
+// shown and the selected record. Input is fed with Add and Text, keys with
+// Key and the screen is drawn from Render. It is safe for concurrent use.
+type Pager struct {
+	mu      sync.Mutex
+	opts    []writer.Option
+	color   bool
+	entries *Ring[*entry]
+	pending *Ring[*entry]
+	partial []byte
+
+	hiddenLevels map[string]bool
+	hiddenFields []string
+	search       string
+	frozen       bool
+	done         bool
+	follow       bool
+	help         bool
+	mode         mode
+	input        string
+	status       string
+
+	sel  *entry
+	top  *entry
+	page int
+
+	// gen is bumped whenever every entry must be rendered again
+	gen       int
+	width     int
+	writerGen int
+	buf       bytes.Buffer
+	render    writer.Writer
+	expand    writer.Writer
+}
+
+// New returns a pager holding up to size records, rendered by a writer.Writer
+// configured with opts.
+func New(size int, color bool, opts ...writer.Option) *Pager {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Pager{
+		opts:         opts,
+		color:        color,
+		entries:      NewRing[*entry](size),
+		pending:      NewRing[*entry](size),
+		hiddenLevels: make(map[string]bool),
+		follow:       true,
+		page:         1,
+		gen:          1,
+	}
+}
+
+// ShowLevels hides every level but levels, an empty levels shows them all.
+func (p *Pager) ShowLevels(levels []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, l := range Levels {
+		p.hiddenLevels[l] = len(levels) > 0 && !gu.Includes(levels, l)
+	}
+}
+
+// Add adds a decoded JSON value, arrays add each of their elements.
+func (p *Pager) Add(a any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(a)
+}
+
+func (p *Pager) add(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		p.push(&entry{
+			value:  v,
+			level:  stats.Level(v),
+			search: strings.ToLower(stats.String(v)),
+		})
+	case []any:
+		for _, vv := range v {
+			p.add(vv)
+		}
+	case nil:
+	default:
+		p.pushText(stats.String(v))
+	}
+}
+
+// Text adds non-JSON input, which may arrive in chunks. Blank lines are
+// dropped.
+func (p *Pager) Text(b []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.partial = append(p.partial, b...)
+	for {
+		i := bytes.IndexByte(p.partial, '\n')
+		if i < 0 {
+			return
+		}
+		p.pushText(string(p.partial[:i]))
+		p.partial = p.partial[i+1:]
+	}
+}
+
+// Done marks the end of the input.
+func (p *Pager) Done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.partial) > 0 {
+		p.pushText(string(p.partial))
+		p.partial = nil
+	}
+	p.done = true
+}
+
+func (p *Pager) pushText(s string) {
+	s = strings.TrimRight(s, "\r")
+	if strings.TrimSpace(s) == "" {
+		return
+	}
+	p.push(&entry{
+		text:   s,
+		level:  stats.NoLevel,
+		search: strings.ToLower(s),
+	})
+}
+
+func (p *Pager) push(e *entry) {
+	if p.frozen {
+		p.pending.Push(e)
+		return
+	}
+	p.entries.Push(e)
+	if p.follow && p.visible(e) {
+		p.sel = e
+	}
+}
+
+// Key handles a key from ParseKeys, returning true when the pager should quit.
+func (p *Pager) Key(k string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.status = ""
+
+	if p.mode != modeNormal {
+		p.prompt(k)
+		return false
+	}
+	if p.help {
+		p.help = false
+		return k == "q" || k == KeyCtrlC
+	}
+
+	switch k {
+	case "q", KeyCtrlC:
+		return true
+	case "j", KeyDown:
+		p.move(1)
+	case "k", KeyUp:
+		p.move(-1)
+	case " ", KeyPageDown:
+		p.move(p.page)
+	case "b", KeyPageUp:
+		p.move(-p.page)
+	case "g", KeyHome:
+		p.move(-p.entries.Len())
+	case "G", KeyEnd:
+		p.move(p.entries.Len())
+	case "/":
+		p.mode, p.input = modeSearch, ""
+	case "n":
+		p.find(1)
+	case "N":
+		p.find(-1)
+	case KeyEnter:
+		if p.sel != nil && p.sel.value != nil {
+			p.sel.expanded = !p.sel.expanded
+			p.sel.lines = nil
+		}
+	case "0":
+		p.hiddenLevels = make(map[string]bool)
+	case "1", "2", "3", "4", "5", "6", "7":
+		l := Levels[k[0]-'1']
+		p.hiddenLevels[l] = !p.hiddenLevels[l]
+	case "-":
+		p.mode, p.input = modeHide, ""
+	case "+":
+		p.hiddenFields = nil
+		p.gen++
+	case "f":
+		p.frozen = !p.frozen
+		if !p.frozen {
+			for i := 0; i < p.pending.Len(); i++ {
+				p.push(p.pending.At(i))
+			}
+			p.pending.Clear()
+		}
+	case "?":
+		p.help = true
+	case KeyEscape:
+		p.search = ""
+	}
+	return false
+}
+
+// prompt handles a key while reading a search or field name.
+func (p *Pager) prompt(k string) {
+	switch k {
+	case KeyEscape, KeyCtrlC:
+		p.mode = modeNormal
+	case KeyBackspace:
+		if p.input == "" {
+			p.mode = modeNormal
+			return
+		}
+		_, n := utf8.DecodeLastRuneInString(p.input)
+		p.input = p.input[:len(p.input)-n]
+	case KeyEnter:
+		m := p.mode
+		p.mode = modeNormal
+		if p.input == "" {
+			return
+		}
+		switch m {
+		case modeSearch:
+			p.search = p.input
+			p.find(1)
+		case modeHide:
+			if !gu.Includes(p.hiddenFields, p.input) {
+				p.hiddenFields = append(p.hiddenFields, p.input)
+				p.gen++
+			}
+		}
+	default:
+		if utf8.RuneCountInString(k) == 1 {
+			p.input += k
+		}
+	}
+}
+
+// move moves the selection by n visible records.
+func (p *Pager) move(n int) {
+	list := p.list()
+	if len(list) == 0 {
+		return
+	}
+	i := p.index(list) + n
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(list) {
+		i = len(list) - 1
+	}
+	p.sel = list[i]
+	p.follow = i == len(list)-1
+}
+
+// find selects the next visible record matching the search in direction dir.
+func (p *Pager) find(dir int) {
+	if p.search == "" {
+		return
+	}
+	q := strings.ToLower(p.search)
+	list := p.list()
+	for i := p.index(list) + dir; i >= 0 && i < len(list); i += dir {
+		if strings.Contains(list[i].search, q) {
+			p.sel = list[i]
+			p.follow = i == len(list)-1
+			return
+		}
+	}
+	p.status = "pattern not found: " + p.search
+}
+
+func (p *Pager) visible(e *entry) bool {
+	return !p.hiddenLevels[e.level]
+}
+
+// list returns the visible entries.
+func (p *Pager) list() []*entry {
+	list := make([]*entry, 0, p.entries.Len())
+	for i := 0; i < p.entries.Len(); i++ {
+		if e := p.entries.At(i); p.visible(e) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// index returns the position of the selection in list, the last entry when
+// following or the first if the selection is no longer shown.
+func (p *Pager) index(list []*entry) int {
+	for i, e := range list {
+		if e == p.sel {
+			return i
+		}
+	}
+	if p.follow && len(list) > 0 {
+		return len(list) - 1
+	}
+	return 0
+}
+
+// Render returns the screen, height lines of at most width columns, with the
+// status line last.
+func (p *Pager) Render(width, height int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if width < 4 || height < 2 {
+		return nil
+	}
+	if width != p.width {
+		p.width = width
+		p.gen++
+	}
+	if p.writerGen != p.gen {
+		p.writers()
+	}
+
+	body := height - 1
+	p.page = body - 1
+	if p.page < 1 {
+		p.page = 1
+	}
+
+	var lines []string
+	if p.help {
+		lines = append(lines, help...)
+	} else {
+		lines = p.body(body)
+	}
+	if len(lines) > body {
+		lines = lines[:body]
+	}
+	for i := range lines {
+		lines[i] = cut(lines[i], width)
+	}
+	for len(lines) < body {
+		lines = append(lines, "")
+	}
+	return append(lines, p.statusLine(width))
+}
+
+func (p *Pager) body(height int) []string {
+	list := p.list()
+	if len(list) == 0 {
+		return nil
+	}
+	sel := p.index(list)
+	p.sel = list[sel]
+
+	top := -1
+	for i, e := range list {
+		if e == p.top {
+			top = i
+			break
+		}
+	}
+	switch {
+	case top < 0 || p.follow:
+		// fill the screen up to the selection, like tail
+		top = sel
+		n := len(p.lines(list[sel]))
+		for top > 0 {
+			n += len(p.lines(list[top-1]))
+			if n > height {
+				break
+			}
+			top--
+		}
+	case top > sel:
+		top = sel
+	}
+	// scroll down until the whole selection fits
+	for top < sel {
+		n := 0
+		for _, e := range list[top : sel+1] {
+			n += len(p.lines(e))
+		}
+		if n <= height {
+			break
+		}
+		top++
+	}
+	p.top = list[top]
+
+	var lines []string
+	for _, e := range list[top:] {
+		gutter := "  "
+		if e == p.sel {
+			gutter = "> "
+			if p.color {
+				gutter = reverse + ">" + reset + " "
+			}
+		}
+		for _, l := range p.lines(e) {
+			lines = append(lines, gutter+l)
+			gutter = "  "
+		}
+		if len(lines) >= height {
+			break
+		}
+	}
+	return lines
+}
+
+// lines returns the rendering of e, from the cache when it is current.
+func (p *Pager) lines(e *entry) []string {
+	if e.lines != nil && e.gen == p.gen {
+		return e.lines
+	}
+	e.gen = p.gen
+	if e.value == nil {
+		e.lines = []string{strings.ReplaceAll(e.text, "\t", strings.Repeat(" ", tabWidth))}
+		return e.lines
+	}
+
+	p.buf.Reset()
+	w := p.render
+	if e.expanded {
+		w = p.expand
+	}
+	_, _ = w.WriteAny(e.value)
+	s := strings.ReplaceAll(strings.TrimRight(p.buf.String(), "\n"), "\t", strings.Repeat(" ", tabWidth))
+	e.lines = strings.Split(s, "\n")
+	for i, l := range e.lines {
+		e.lines[i] = strings.TrimRight(l, " ")
+	}
+	return e.lines
+}
+
+// writers configures the writers for the current width and hidden fields.
+func (p *Pager) writers() {
+	opts := append([]writer.Option{}, p.opts...)
+	opts = append(opts, writer.WithOut(&p.buf), writer.WithExcludeKeysAppend(p.hiddenFields))
+	p.render = writer.New(append(opts, writer.WithWrap(p.width-2))...)
+	p.expand = writer.New(append(opts, writer.WithVertical(true), writer.WithFlatten(true))...)
+	p.writerGen = p.gen
+}
+
+func (p *Pager) statusLine(width int) string {
+	var left string
+	switch p.mode {
+	case modeSearch:
+		return "/" + p.input
+	case modeHide:
+		return "hide field: " + p.input
+	}
+
+	list := p.list()
+	pos := 0
+	if len(list) > 0 {
+		pos = p.index(list) + 1
+	}
+	left = fmt.Sprintf(" %d/%d", pos, len(list))
+	if d := p.entries.Dropped(); d > 0 {
+		left += fmt.Sprintf(" (%d dropped)", d)
+	}
+	var hidden []string
+	for _, l := range Levels {
+		if p.hiddenLevels[l] {
+			hidden = append(hidden, l)
+		}
+	}
+	if len(hidden) > 0 {
+		left += " -" + strings.Join(hidden, " -")
+	}
+	if len(p.hiddenFields) > 0 {
+		left += " hidden: " + strings.Join(p.hiddenFields, ",")
+	}
+	if p.search != "" {
+		left += " /" + p.search
+	}
+	switch {
+	case p.frozen:
+		left += fmt.Sprintf(" FROZEN +%d", p.pending.Len())
+	case !p.done:
+		left += " live"
+	}
+	if p.status != "" {
+		left += "  " + p.status
+	}
+
+	right := "? help "
+	pad := width - utf8.RuneCountInString(left) - utf8.RuneCountInString(right)
+	if pad < 1 {
+		pad = 1
+	}
+	s := cut(left+strings.Repeat(" ", pad)+right, width)
+	if p.color {
+		return reverse + s + reset
+	}
+	return s
+}
+
+// cut truncates s to width visible columns, keeping color escape sequences
+// and resetting the color if any were cut.
+func cut(s string, width int) string {
+	if formatter.VisibleLen(s) <= width {
+		return s
+	}
+	var sb strings.Builder
+	n := 0
+	colored := false
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			j := strings.IndexByte(s[i:], 'm')
+			if j < 0 {
+				break
+			}
+			sb.WriteString(s[i : i+j+1])
+			colored = true
+			i += j + 1
+			continue
+		}
+		if n == width {
+			break
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		sb.WriteString(s[i : i+size])
+		n++
+		i += size
+	}
+	if colored {
+		sb.WriteString(reset)
+	}
+	return sb.String()
+}
@@ -0,0 +1,102 @@
+package pager_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/pager"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/stretchr/testify/require"
+)
+
+var records = []string{
+	`{"level":"debug","time":"2022-08-03T12:34:20Z","message":"starting","version":"1.2.3"}`,
+	`{"level":"info","time":"2022-08-03T12:34:21Z","message":"request","path":"/api/users","status":200}`,
+	`{"level":"warn","time":"2022-08-03T12:34:22Z","message":"slow request","path":"/api/orders","elapsed_ms":1532}`,
+	`{"level":"error","time":"2022-08-03T12:34:23Z","message":"query failed","error":"connection refused","db":{"host":"db-1","port":5432}}`,
+	`{"level":"info","time":"2022-08-03T12:34:24Z","message":"request","path":"/api/orders","status":201}`,
+}
+
+func newPager(t *testing.T, size int) *pager.Pager {
+	p := pager.New(size, false, writer.WithColor(false))
+	for _, r := range records {
+		var a any
+		d := json.NewDecoder(strings.NewReader(r))
+		d.UseNumber()
+		require.NoError(t, d.Decode(&a))
+		p.Add(a)
+	}
+	p.Text([]byte("plain text\n\n  \nsplit "))
+	p.Text([]byte("line\n"))
+	return p
+}
+
+func keys(p *pager.Pager, keys ...string) {
+	for _, k := range keys {
+		p.Key(k)
+	}
+}
+
+func screen(p *pager.Pager) []byte {
+	return []byte(strings.Join(p.Render(60, 8), "\n") + "\n")
+}
+
+func TestPager(t *testing.T) {
+	testcases := map[string][]string{
+		"follow":      nil,
+		"first":       {"g"},
+		"move":        {"g", "j", "j"},
+		"page":        {"g", " "},
+		"search":      {"g", "/", "r", "e", "q", pager.KeyEnter},
+		"search-next": {"g", "/", "r", "e", "q", pager.KeyEnter, "n"},
+		"not-found":   {"/", "n", "o", "p", "e", pager.KeyEnter},
+		"levels":      {"g", "3", "2"},
+		"levels-all":  {"g", "3", "2", "0"},
+		"expand":      {"g", "j", "j", "j", pager.KeyEnter},
+		"hide":        {"g", "-", "p", "a", "t", "h", pager.KeyEnter},
+		"hide-nested": {"g", "j", "j", "j", pager.KeyEnter, "-", "d", "b", ".", "h", "o", "s", "t", pager.KeyEnter},
+		"show":        {"g", "-", "p", "a", "t", "h", pager.KeyEnter, "+"},
+		"prompt":      {"/", "a", "b", pager.KeyBackspace},
+		"help":        {"?"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			p := newPager(t, 0)
+			// keys are pressed on a drawn screen, which sets the page size
+			screen(p)
+			keys(p, tc...)
+			golden.Assert(t, screen(p))
+		})
+	}
+}
+
+func TestPager_Freeze(t *testing.T) {
+	p := newPager(t, 0)
+	keys(p, "f")
+	p.Add(map[string]any{"level": "info", "message": "while frozen"})
+	golden.Assert(t, screen(p))
+
+	keys(p, "f")
+	require.Contains(t, string(screen(p)), "while frozen")
+}
+
+func TestPager_Bounded(t *testing.T) {
+	p := newPager(t, 3)
+	p.Done()
+	golden.Assert(t, screen(p))
+}
+
+func TestPager_Quit(t *testing.T) {
+	p := newPager(t, 0)
+	require.False(t, p.Key("j"))
+	require.False(t, p.Key("?"))
+	require.False(t, p.Key("x"))
+	require.True(t, p.Key("q"))
+	require.True(t, p.Key(pager.KeyCtrlC))
+
+	// q is a character while typing a search
+	keys(p, "/")
+	require.False(t, p.Key("q"))
+}
@@ -0,0 +1,55 @@
+package pager
+
+// Ring holds the most recent items up to a fixed capacity, dropping the oldest
+// once full.
+type Ring[T any] struct {
+	items   []T
+	start   int
+	n       int
+	dropped int
+}
+
+func NewRing[T any](capacity int) *Ring[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Ring[T]{
+		items: make([]T, capacity),
+	}
+}
+
+// Push adds v, dropping the oldest item if the ring is full.
+func (r *Ring[T]) Push(v T) {
+	if r.n < len(r.items) {
+		r.items[(r.start+r.n)%len(r.items)] = v
+		r.n++
+		return
+	}
+	r.items[r.start] = v
+	r.start = (r.start + 1) % len(r.items)
+	r.dropped++
+}
+
+// Len returns the number of items held.
+func (r *Ring[T]) Len() int {
+	return r.n
+}
+
+// At returns the ith oldest item held.
+func (r *Ring[T]) At(i int) T {
+	return r.items[(r.start+i)%len(r.items)]
+}
+
+// Dropped returns the number of items dropped to make room.
+func (r *Ring[T]) Dropped() int {
+	return r.dropped
+}
+
+// Clear removes every item.
+func (r *Ring[T]) Clear() {
+	var zero T
+	for i := range r.items {
+		r.items[i] = zero
+	}
+	r.start, r.n = 0, 0
+}
@@ -0,0 +1,37 @@
+package pager_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pager"
+	"github.com/stretchr/testify/require"
+)
+
+func items(r *pager.Ring[int]) []int {
+	var out []int
+	for i := 0; i < r.Len(); i++ {
+		out = append(out, r.At(i))
+	}
+	return out
+}
+
+func TestRing(t *testing.T) {
+	r := pager.NewRing[int](3)
+	require.Empty(t, items(r))
+
+	r.Push(1)
+	r.Push(2)
+	require.Equal(t, []int{1, 2}, items(r))
+	require.Equal(t, 0, r.Dropped())
+
+	r.Push(3)
+	r.Push(4)
+	r.Push(5)
+	require.Equal(t, []int{3, 4, 5}, items(r))
+	require.Equal(t, 2, r.Dropped())
+
+	r.Clear()
+	require.Empty(t, items(r))
+	r.Push(6)
+	require.Equal(t, []int{6}, items(r))
+}
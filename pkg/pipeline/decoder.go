@@ -0,0 +1,86 @@
+package pipeline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dcilke/heron"
+)
+
+// Decoder turns a stream into records.
+type Decoder interface {
+	// Decode reads r to the end, calling emit with each record.
+	Decode(r io.Reader, emit func(Record)) error
+	// Flush emits any records still buffered.
+	Flush(emit func(Record))
+}
+
+type jsonDecoder struct {
+	h    *heron.Heron
+	emit func(Record)
+	errs []error
+}
+
+// JSON returns a Decoder extracting JSON values from the stream, as the hz
+// command line does. Text between the values is emitted as text records
+// unless the buffer is disabled with heron.WithBufSize(0).
+func JSON(opts ...heron.Option) Decoder {
+	d := &jsonDecoder{}
+	opts = append([]heron.Option{heron.WithBufSize(heron.DefaultBufSize)}, opts...)
+	d.h = heron.New(append(opts,
+		heron.WithJSON(func(a any) {
+			d.emit(Record{Value: a})
+		}),
+		heron.WithBytes(func(b []byte) {
+			d.emit(Record{Text: b})
+		}),
+		heron.WithError(func(err error) {
+			d.errs = append(d.errs, fmt.Errorf("extractor error: %w", err))
+		}),
+	)...)
+	return d
+}
+
+func (d *jsonDecoder) Decode(r io.Reader, emit func(Record)) error {
+	d.emit = emit
+	d.errs = nil
+	d.h.Process(r)
+	return errors.Join(d.errs...)
+}
+
+func (d *jsonDecoder) Flush(emit func(Record)) {
+	d.emit = emit
+	d.h.Flush()
+}
+
+type logfmtDecoder struct{}
+
+// Logfmt returns a Decoder reading a logfmt record per line. Lines which are
+// not logfmt are emitted as text records.
+func Logfmt() Decoder {
+	return logfmtDecoder{}
+}
+
+func (logfmtDecoder) Decode(r io.Reader, emit func(Record)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if m, ok := ParseLogfmt(line); ok {
+				emit(Record{Value: m})
+			} else {
+				emit(Record{Text: line})
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (logfmtDecoder) Flush(func(Record)) {}
@@ -0,0 +1,46 @@
+package pipeline_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dcilke/heron"
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, dec pipeline.Decoder, input string) []pipeline.Record {
+	t.Helper()
+	var records []pipeline.Record
+	emit := func(r pipeline.Record) { records = append(records, r) }
+	require.NoError(t, dec.Decode(strings.NewReader(input), emit))
+	dec.Flush(emit)
+	return records
+}
+
+func TestJSON(t *testing.T) {
+	records := decode(t, pipeline.JSON(), "{\"a\":1}\nplain text\n")
+	require.Equal(t, pipeline.Record{Value: map[string]any{"a": json.Number("1")}}, records[0])
+	var text string
+	for _, r := range records[1:] {
+		require.True(t, r.IsText())
+		text += string(r.Text)
+	}
+	require.Equal(t, "\nplain text\n", text)
+}
+
+func TestJSON_NoText(t *testing.T) {
+	records := decode(t, pipeline.JSON(heron.WithBufSize(0)), "{\"a\":1}\nplain text\n")
+	require.Len(t, records, 1)
+	require.False(t, records[0].IsText())
+}
+
+func TestLogfmt(t *testing.T) {
+	records := decode(t, pipeline.Logfmt(), "level=info msg=\"hello world\" n=5\nplain text\nlast=line")
+	require.Equal(t, []pipeline.Record{
+		{Value: map[string]any{"level": "info", "msg": "hello world", "n": json.Number("5")}},
+		{Text: []byte("plain text\n")},
+		{Value: map[string]any{"last": "line"}},
+	}, records)
+}
@@ -0,0 +1,37 @@
+package pipeline
+
+import (
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+// Filter decides which records continue down the pipeline.
+type Filter interface {
+	// Keep reports whether r is kept.
+	Keep(r Record) bool
+}
+
+// FilterFunc adapts a function to a Filter.
+type FilterFunc func(Record) bool
+
+func (f FilterFunc) Keep(r Record) bool {
+	return f(r)
+}
+
+// Levels keeps objects at one of levels, as the --level flag does. Text and
+// values which are not objects are kept, as are all records if levels is empty.
+func Levels(levels ...string) Filter {
+	return FilterFunc(func(r Record) bool {
+		m, ok := r.Map()
+		if !ok {
+			return true
+		}
+		return formatter.MatchLevels(m, levels)
+	})
+}
+
+// Strict drops text records, keeping only decoded values.
+func Strict() Filter {
+	return FilterFunc(func(r Record) bool {
+		return !r.IsText()
+	})
+}
@@ -0,0 +1,24 @@
+package pipeline_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLevels(t *testing.T) {
+	f := pipeline.Levels("warn", "error")
+	require.True(t, f.Keep(pipeline.Record{Value: map[string]any{"level": "error"}}))
+	require.False(t, f.Keep(pipeline.Record{Value: map[string]any{"level": "info"}}))
+	require.True(t, f.Keep(pipeline.Record{Text: []byte("text")}))
+	require.True(t, f.Keep(pipeline.Record{Value: []any{map[string]any{"level": "info"}}}))
+
+	require.True(t, pipeline.Levels().Keep(pipeline.Record{Value: map[string]any{"level": "info"}}))
+}
+
+func TestStrict(t *testing.T) {
+	f := pipeline.Strict()
+	require.True(t, f.Keep(pipeline.Record{Value: map[string]any{}}))
+	require.False(t, f.Keep(pipeline.Record{Text: []byte("text")}))
+}
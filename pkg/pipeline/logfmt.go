@@ -0,0 +1,132 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseLogfmt parses a line of key=value pairs. Values may be quoted, a key
+// without a value is true and numbers are kept as json.Number. It reports
+// false unless the whole line parses with at least one key=value pair.
+func ParseLogfmt(line []byte) (map[string]any, bool) {
+	s := string(bytes.TrimSpace(line))
+	m := make(map[string]any)
+	pairs := 0
+	for len(s) > 0 {
+		i := strings.IndexAny(s, "= \t")
+		if i == 0 {
+			return nil, false
+		}
+		if i < 0 || s[i] != '=' {
+			// a bare key
+			if i < 0 {
+				i = len(s)
+			}
+			m[s[:i]] = true
+			s = strings.TrimLeft(s[i:], " \t")
+			continue
+		}
+		key := s[:i]
+		s = s[i+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			q, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, false
+			}
+			value, _ = strconv.Unquote(q)
+			s = s[len(q):]
+			if len(s) > 0 && s[0] != ' ' && s[0] != '\t' {
+				return nil, false
+			}
+		} else {
+			j := strings.IndexAny(s, " \t")
+			if j < 0 {
+				j = len(s)
+			}
+			value = s[:j]
+			if strings.ContainsAny(value, `="`) {
+				return nil, false
+			}
+			s = s[j:]
+		}
+		m[key] = logfmtValue(value)
+		pairs++
+		s = strings.TrimLeft(s, " \t")
+	}
+	return m, pairs > 0
+}
+
+func logfmtValue(s string) any {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if s != "" && json.Valid([]byte(s)) && (s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) {
+		return json.Number(s)
+	}
+	return s
+}
+
+// FormatLogfmt appends m to buf as logfmt, with keys sorted and nested objects
+// flattened with dots.
+func FormatLogfmt(buf *bytes.Buffer, m map[string]any) {
+	flat := make(map[string]any, len(m))
+	flattenInto(flat, "", m)
+	keys := make([]string, 0, len(flat))
+	for k := range flat {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(logfmtQuote(flat[k]))
+	}
+}
+
+func flattenInto(out map[string]any, prefix string, m map[string]any) {
+	for k, v := range m {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if mm, ok := v.(map[string]any); ok && len(mm) > 0 {
+			flattenInto(out, k, mm)
+			continue
+		}
+		out[k] = v
+	}
+}
+
+func logfmtQuote(v any) string {
+	var s string
+	switch vv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		s = vv
+	case []any, map[string]any:
+		b, err := json.Marshal(vv)
+		if err != nil {
+			s = fmt.Sprint(vv)
+		} else {
+			s = string(b)
+		}
+	default:
+		s = fmt.Sprint(vv)
+	}
+	if s == "" || strings.ContainsAny(s, " \t\"=\\") || strings.IndexFunc(s, func(r rune) bool { return r < ' ' }) >= 0 {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLogfmt(t *testing.T) {
+	testcases := map[string]struct {
+		line string
+		want map[string]any
+	}{
+		"pairs":   {"a=1 b=two", map[string]any{"a": json.Number("1"), "b": "two"}},
+		"quoted":  {`msg="hello \"world\"" x=y`, map[string]any{"msg": `hello "world"`, "x": "y"}},
+		"empty":   {`a= b=""`, map[string]any{"a": "", "b": ""}},
+		"bare":    {"a=1 debug", map[string]any{"a": json.Number("1"), "debug": true}},
+		"bool":    {"ok=true failed=false", map[string]any{"ok": true, "failed": false}},
+		"float":   {"ratio=-0.5 v=1.2.3", map[string]any{"ratio": json.Number("-0.5"), "v": "1.2.3"}},
+		"spacing": {"  a=1\t b=2 \n", map[string]any{"a": json.Number("1"), "b": json.Number("2")}},
+		"text":    {"hello world", nil},
+		"blank":   {"", nil},
+		"unquote": {`a="open`, nil},
+		"joined":  {`a="x"b`, nil},
+		"no-key":  {`=x`, nil},
+		"equals":  {`a=b=c`, nil},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			m, ok := pipeline.ParseLogfmt([]byte(tc.line))
+			require.Equal(t, tc.want != nil, ok)
+			if ok {
+				require.Equal(t, tc.want, m)
+			}
+		})
+	}
+}
+
+func TestFormatLogfmt(t *testing.T) {
+	buf := new(bytes.Buffer)
+	pipeline.FormatLogfmt(buf, map[string]any{
+		"msg":    "hello world",
+		"n":      json.Number("5"),
+		"empty":  "",
+		"nil":    nil,
+		"req":    map[string]any{"id": "abc", "tags": []any{"a", "b"}},
+		"quote":  `say "hi"`,
+		"simple": "x",
+	})
+	require.Equal(t, `empty="" msg="hello world" n=5 nil= quote="say \"hi\"" req.id=abc req.tags="[\"a\",\"b\"]" simple=x`, buf.String())
+
+	m, ok := pipeline.ParseLogfmt(buf.Bytes())
+	require.True(t, ok)
+	require.Equal(t, "hello world", m["msg"])
+	require.Equal(t, `say "hi"`, m["quote"])
+}
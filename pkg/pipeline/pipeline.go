@@ -0,0 +1,145 @@
+// Package pipeline wires the stages hz is built from: sources are decoded into
+// records which pass through filters and transforms on their way to a sink.
+//
+//	p := pipeline.New(pipeline.JSON(), pipeline.Pretty(writer.New()),
+//		pipeline.WithFilter(pipeline.Levels("warn", "error")),
+//		pipeline.WithTransform(pipeline.Redact("password")),
+//	)
+//	err := p.Run(ctx, pipeline.Stdin())
+package pipeline
+
+import (
+	"context"
+)
+
+// Record is a decoded value, or Text which could not be decoded.
+type Record struct {
+	// Value is the decoded value, usually a map[string]any.
+	Value any
+	// Text is the raw input of a record which is not structured, nil otherwise.
+	Text []byte
+}
+
+// IsText reports whether r holds raw text rather than a decoded value.
+func (r Record) IsText() bool {
+	return r.Text != nil
+}
+
+// Map returns the value of r if it is an object.
+func (r Record) Map() (map[string]any, bool) {
+	m, ok := r.Value.(map[string]any)
+	return m, ok
+}
+
+// Option configures a Pipeline.
+type Option func(*Pipeline)
+
+// WithFilter adds a filter stage. Stages run in the order they are added.
+func WithFilter(f Filter) Option {
+	return func(p *Pipeline) {
+		p.stages = append(p.stages, func(r Record, next func(Record)) {
+			if f.Keep(r) {
+				next(r)
+			}
+		})
+	}
+}
+
+// WithTransform adds a transform stage. Stages run in the order they are added.
+func WithTransform(t Transform) Option {
+	return func(p *Pipeline) {
+		p.stages = append(p.stages, func(r Record, next func(Record)) {
+			next(t.Apply(r))
+		})
+	}
+}
+
+// WithExpander adds an expander stage. Stages run in the order they are added.
+func WithExpander(e Expander) Option {
+	return func(p *Pipeline) {
+		p.stages = append(p.stages, func(r Record, next func(Record)) {
+			records, err := e.Expand(r)
+			if err != nil {
+				p.onError(err)
+			}
+			for _, r := range records {
+				next(r)
+			}
+		})
+	}
+}
+
+// WithErrorHandler sets the function called with errors opening sources,
+// decoding, expanding and writing to the sink. Errors are dropped by default.
+func WithErrorHandler(fn func(error)) Option {
+	return func(p *Pipeline) {
+		p.onError = fn
+	}
+}
+
+// stage is a filter, transform or expander, passing the records it keeps to next.
+type stage func(r Record, next func(Record))
+
+// Pipeline reads sources through a decoder, passing each record through its
+// stages to the sink.
+type Pipeline struct {
+	decoder Decoder
+	sink    Sink
+	stages  []stage
+	onError func(error)
+}
+
+// New returns a Pipeline decoding with dec and writing to sink.
+func New(dec Decoder, sink Sink, opts ...Option) *Pipeline {
+	p := &Pipeline{
+		decoder: dec,
+		sink:    sink,
+		onError: func(error) {},
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Run reads each source in turn until they are exhausted or ctx is done. A
+// source which fails to open is reported and skipped.
+func (p *Pipeline) Run(ctx context.Context, sources ...Source) error {
+	for _, src := range sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := src.Open(ctx)
+		if err != nil {
+			p.onError(err)
+			continue
+		}
+		if err := p.decoder.Decode(r, p.emit); err != nil {
+			p.onError(err)
+		}
+		r.Close()
+	}
+	return ctx.Err()
+}
+
+// Flush emits the records still buffered by the decoder.
+func (p *Pipeline) Flush() {
+	p.decoder.Flush(p.emit)
+}
+
+func (p *Pipeline) emit(r Record) {
+	p.run(0, r)
+}
+
+// run passes r through the stages from i on, then to the sink.
+func (p *Pipeline) run(i int, r Record) {
+	if i == len(p.stages) {
+		if err := p.sink.Write(r); err != nil {
+			p.onError(err)
+		}
+		return
+	}
+	p.stages[i](r, func(r Record) {
+		p.run(i+1, r)
+	})
+}
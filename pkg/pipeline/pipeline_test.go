@@ -0,0 +1,123 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/stretchr/testify/require"
+)
+
+const input = `{"level":"info","message":"started","password":"hunter2"}
+not json
+{"level":"debug","message":"details"}
+{"level":"error","message":"failed","req":{"id":"abc"}}
+`
+
+func TestPipeline(t *testing.T) {
+	buf := new(bytes.Buffer)
+	p := pipeline.New(pipeline.JSON(), pipeline.JSONSink(buf),
+		pipeline.WithFilter(pipeline.Strict()),
+		pipeline.WithFilter(pipeline.Levels("info", "error")),
+		pipeline.WithTransform(pipeline.Redact("password")),
+		pipeline.WithTransform(pipeline.Rename(map[string]string{"req.id": "request_id"})),
+	)
+	require.NoError(t, p.Run(context.Background(), pipeline.Reader(strings.NewReader(input))))
+	p.Flush()
+	require.Equal(t, `{"level":"info","message":"started","password":"[REDACTED]"}
+{"level":"error","message":"failed","request_id":"abc"}
+`, buf.String())
+}
+
+func TestPipeline_Pretty(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := writer.New(
+		writer.WithOut(buf),
+		writer.WithColor(false),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+	)
+	p := pipeline.New(pipeline.JSON(), pipeline.Pretty(w))
+	require.NoError(t, p.Run(context.Background(), pipeline.Reader(strings.NewReader(input))))
+	p.Flush()
+	require.Equal(t, `INF started password=hunter2
+not json
+DBG details
+ERR failed req={"id":"abc"}
+`, buf.String())
+}
+
+func TestPipeline_Sources(t *testing.T) {
+	var errs []error
+	var got []string
+	sink := pipeline.SinkFunc(func(r pipeline.Record) error {
+		m, _ := r.Map()
+		got = append(got, m["message"].(string))
+		return nil
+	})
+	p := pipeline.New(pipeline.JSON(), sink, pipeline.WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	err := p.Run(context.Background(),
+		pipeline.Reader(strings.NewReader(`{"message":"one"}`)),
+		pipeline.File("testdata/missing.log"),
+		pipeline.Reader(strings.NewReader(`{"message":"two"}`)),
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"one", "two"}, got)
+	require.Len(t, errs, 1)
+	require.Contains(t, errs[0].Error(), `unable to open "testdata/missing.log"`)
+}
+
+func TestPipeline_SinkError(t *testing.T) {
+	var errs []error
+	sink := pipeline.SinkFunc(func(pipeline.Record) error { return io.ErrShortWrite })
+	p := pipeline.New(pipeline.JSON(), sink, pipeline.WithErrorHandler(func(err error) {
+		errs = append(errs, err)
+	}))
+	require.NoError(t, p.Run(context.Background(), pipeline.Reader(strings.NewReader(`{"a":1}`))))
+	require.Len(t, errs, 1)
+	require.True(t, errors.Is(errs[0], io.ErrShortWrite))
+}
+
+func TestPipeline_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := pipeline.New(pipeline.JSON(), pipeline.SinkFunc(func(pipeline.Record) error {
+		t.Fatal("unexpected record")
+		return nil
+	}))
+	require.ErrorIs(t, p.Run(ctx, pipeline.Reader(strings.NewReader(`{"a":1}`))), context.Canceled)
+}
+
+func TestPipeline_Expander(t *testing.T) {
+	var errs []error
+	buf := new(bytes.Buffer)
+	p := pipeline.New(pipeline.JSON(), pipeline.JSONSink(buf),
+		pipeline.WithFilter(pipeline.Strict()),
+		pipeline.WithExpander(pipeline.ExpanderFunc(func(r pipeline.Record) ([]pipeline.Record, error) {
+			m, _ := r.Map()
+			switch m["level"] {
+			case "debug":
+				return nil, nil
+			case "error":
+				return []pipeline.Record{r, {Value: map[string]any{"alert": m["message"]}}}, nil
+			}
+			return []pipeline.Record{r}, errors.New("kept")
+		})),
+		pipeline.WithTransform(pipeline.Redact("password")),
+		pipeline.WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}),
+	)
+	require.NoError(t, p.Run(context.Background(), pipeline.Reader(strings.NewReader(input))))
+	require.Equal(t, `{"level":"info","message":"started","password":"[REDACTED]"}
+{"level":"error","message":"failed","req":{"id":"abc"}}
+{"alert":"failed"}
+`, buf.String())
+	require.Len(t, errs, 1)
+}
@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/dcilke/hz/pkg/writer"
+)
+
+const newline = "\n"
+
+// Sink is the end of a pipeline.
+type Sink interface {
+	// Write outputs r.
+	Write(r Record) error
+}
+
+// SinkFunc adapts a function to a Sink.
+type SinkFunc func(Record) error
+
+func (f SinkFunc) Write(r Record) error {
+	return f(r)
+}
+
+// Tee returns a Sink writing each record to all of sinks, in order.
+func Tee(sinks ...Sink) Sink {
+	return SinkFunc(func(r Record) error {
+		var errs []error
+		for _, s := range sinks {
+			if err := s.Write(r); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
+
+type pretty struct {
+	w writer.Writer
+	// didnl is used to prevent double newlines since we want to ensure each
+	// record is on its own line but we want to preserve as much of the text as
+	// possible
+	didnl bool
+}
+
+// Pretty returns a Sink formatting records with w, as the hz command line
+// does. Each value is written on its own line, text is written unchanged.
+func Pretty(w writer.Writer) Sink {
+	return &pretty{w: w, didnl: true}
+}
+
+func (p *pretty) Write(r Record) error {
+	if r.IsText() {
+		s := string(r.Text)
+		if s == newline && p.didnl {
+			return nil
+		}
+		p.didnl = false
+		_, err := p.w.Print(s)
+		return err
+	}
+	n, err := p.w.WriteLine(r.Value)
+	if n > 0 {
+		p.didnl = true
+	}
+	return err
+}
+
+// encoder writes a record per line, serializing writes to out.
+type encoder struct {
+	mu     sync.Mutex
+	out    io.Writer
+	encode func(*bytes.Buffer, any) error
+}
+
+func (e *encoder) Write(r Record) error {
+	var buf bytes.Buffer
+	if r.IsText() {
+		buf.Write(r.Text)
+	} else {
+		if err := e.encode(&buf, r.Value); err != nil {
+			return err
+		}
+		buf.WriteString(newline)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	_, err := buf.WriteTo(e.out)
+	return err
+}
+
+// JSONSink returns a Sink writing each value to out as a line of JSON. Text
+// is written unchanged, filter it with Strict to output only JSON.
+func JSONSink(out io.Writer) Sink {
+	return &encoder{out: out, encode: func(buf *bytes.Buffer, v any) error {
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		// drop the encoder's newline, added along with text
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}}
+}
+
+// LogfmtSink returns a Sink writing each object to out as a line of logfmt,
+// the elements of arrays on a line each. Text is written unchanged.
+func LogfmtSink(out io.Writer) Sink {
+	var encode func(*bytes.Buffer, any) error
+	encode = func(buf *bytes.Buffer, v any) error {
+		switch vv := v.(type) {
+		case map[string]any:
+			FormatLogfmt(buf, vv)
+		case []any:
+			for i, e := range vv {
+				if i > 0 {
+					buf.WriteString(newline)
+				}
+				if err := encode(buf, e); err != nil {
+					return err
+				}
+			}
+		default:
+			fmt.Fprint(buf, vv)
+		}
+		return nil
+	}
+	return &encoder{out: out, encode: encode}
+}
@@ -0,0 +1,68 @@
+package pipeline_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/writer"
+	"github.com/stretchr/testify/require"
+)
+
+func write(t *testing.T, s pipeline.Sink, records ...pipeline.Record) {
+	t.Helper()
+	for _, r := range records {
+		require.NoError(t, s.Write(r))
+	}
+}
+
+func TestPretty(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := writer.New(
+		writer.WithOut(buf),
+		writer.WithColor(false),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+	)
+	write(t, pipeline.Pretty(w),
+		pipeline.Record{Value: j{"level": "info", "message": "hello"}},
+		pipeline.Record{Text: []byte("\n")},
+		pipeline.Record{Text: []byte("text\n")},
+		pipeline.Record{Text: []byte("\n")},
+	)
+	require.Equal(t, "INF hello\ntext\n\n", buf.String())
+}
+
+func TestJSONSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	write(t, pipeline.JSONSink(buf),
+		pipeline.Record{Value: j{"b": json.Number("1"), "a": "<html>"}},
+		pipeline.Record{Text: []byte("text\n")},
+		pipeline.Record{Value: a{j{"a": true}}},
+	)
+	require.Equal(t, "{\"a\":\"<html>\",\"b\":1}\ntext\n[{\"a\":true}]\n", buf.String())
+}
+
+func TestLogfmtSink(t *testing.T) {
+	buf := new(bytes.Buffer)
+	write(t, pipeline.LogfmtSink(buf),
+		pipeline.Record{Value: j{"level": "info", "msg": "hello world"}},
+		pipeline.Record{Text: []byte("text\n")},
+		pipeline.Record{Value: a{j{"a": json.Number("1")}, j{"b": json.Number("2")}}},
+		pipeline.Record{Value: "scalar"},
+	)
+	require.Equal(t, "level=info msg=\"hello world\"\ntext\na=1\nb=2\nscalar\n", buf.String())
+}
+
+func TestTee(t *testing.T) {
+	var got []pipeline.Record
+	first := pipeline.SinkFunc(func(pipeline.Record) error { return errors.New("first") })
+	second := pipeline.SinkFunc(func(r pipeline.Record) error {
+		got = append(got, r)
+		return nil
+	})
+	err := pipeline.Tee(first, second).Write(pipeline.Record{Value: j{}})
+	require.EqualError(t, err, "first")
+	require.Len(t, got, 1)
+}
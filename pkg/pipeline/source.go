@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+)
+
+// Source is a stream of input.
+type Source interface {
+	// Open returns the stream to read, which is closed once decoded.
+	Open(ctx context.Context) (io.ReadCloser, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(ctx context.Context) (io.ReadCloser, error)
+
+func (f SourceFunc) Open(ctx context.Context) (io.ReadCloser, error) {
+	return f(ctx)
+}
+
+// File returns a Source reading the named file.
+func File(name string) Source {
+	return SourceFunc(func(context.Context) (io.ReadCloser, error) {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open %q: %w", name, err)
+		}
+		return f, nil
+	})
+}
+
+// Files returns a Source for each of the named files.
+func Files(names ...string) []Source {
+	sources := make([]Source, 0, len(names))
+	for _, name := range names {
+		sources = append(sources, File(name))
+	}
+	return sources
+}
+
+// Stdin returns a Source reading stdin, which is left open.
+func Stdin() Source {
+	return Reader(os.Stdin)
+}
+
+// Reader returns a Source reading r, which is left open.
+func Reader(r io.Reader) Source {
+	return SourceFunc(func(context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	})
+}
+
+// Listener is a Source reading from the network. Lines from concurrent
+// connections are merged whole, each datagram of a packet network is read as
+// a line.
+type Listener struct {
+	network string
+	address string
+
+	mu    sync.Mutex
+	addr  net.Addr
+	ready chan struct{}
+}
+
+// Listen returns a Listener on the network ("tcp", "unix", "udp", ...) and
+// address, as for net.Listen and net.ListenPacket. It stops when the context
+// given to Open is done.
+func Listen(network, address string) *Listener {
+	return &Listener{network: network, address: address, ready: make(chan struct{})}
+}
+
+// Addr returns the address listened on, waiting until the Listener is open.
+func (l *Listener) Addr() net.Addr {
+	<-l.ready
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.addr
+}
+
+func (l *Listener) Open(ctx context.Context) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+	var closer io.Closer
+	switch l.network {
+	case "udp", "udp4", "udp6", "unixgram":
+		pc, err := net.ListenPacket(l.network, l.address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on %s %q: %w", l.network, l.address, err)
+		}
+		l.listening(pc.LocalAddr())
+		closer = pc
+		go readPackets(pc, pw)
+	default:
+		ln, err := net.Listen(l.network, l.address)
+		if err != nil {
+			return nil, fmt.Errorf("unable to listen on %s %q: %w", l.network, l.address, err)
+		}
+		l.listening(ln.Addr())
+		closer = ln
+		go accept(ln, pw)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		closer.Close()
+		pw.Close()
+	}()
+	return &listenReader{PipeReader: pr, done: done}, nil
+}
+
+func (l *Listener) listening(addr net.Addr) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.addr = addr
+	select {
+	case <-l.ready:
+	default:
+		close(l.ready)
+	}
+}
+
+type listenReader struct {
+	*io.PipeReader
+	once sync.Once
+	done chan struct{}
+}
+
+func (r *listenReader) Close() error {
+	r.once.Do(func() { close(r.done) })
+	return r.PipeReader.Close()
+}
+
+// accept reads lines from each connection to ln, writing them whole to w.
+func accept(ln net.Listener, w io.Writer) {
+	var mu sync.Mutex
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadBytes('\n')
+				if len(line) > 0 {
+					if line[len(line)-1] != '\n' {
+						line = append(line, '\n')
+					}
+					mu.Lock()
+					_, werr := w.Write(line)
+					mu.Unlock()
+					if werr != nil {
+						return
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// readPackets writes each datagram read from pc to w as a line.
+func readPackets(pc net.PacketConn, w io.Writer) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		if n == 0 {
+			continue
+		}
+		p := buf[:n]
+		if p[n-1] != '\n' {
+			p = append(p, '\n')
+		}
+		if _, err := w.Write(p); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package pipeline_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFiles(t *testing.T) {
+	sources := pipeline.Files("testdata/app.log", "testdata/app.log")
+	require.Len(t, sources, 2)
+
+	r, err := sources[0].Open(context.Background())
+	require.NoError(t, err)
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "{\"level\":\"info\",\"message\":\"from a file\"}\n", string(b))
+}
+
+func TestListen_TCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	l := pipeline.Listen("tcp", "127.0.0.1:0")
+	r, err := l.Open(ctx)
+	require.NoError(t, err)
+	defer r.Close()
+
+	const conns, lines = 4, 20
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", l.Addr().String())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			for n := 0; n < lines; n++ {
+				fmt.Fprintf(conn, "{\"message\":\"c%d-%d\"}\n", i, n)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// lines from concurrent connections are never split
+	br := bufio.NewReader(r)
+	got := make([]string, 0, conns*lines)
+	for len(got) < conns*lines {
+		line, err := br.ReadString('\n')
+		require.NoError(t, err)
+		require.Regexp(t, `^\{"message":"c\d-\d+"\}\n$`, line)
+		got = append(got, line)
+	}
+	sort.Strings(got)
+	require.Equal(t, "{\"message\":\"c0-0\"}\n", got[0])
+
+	cancel()
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+}
+
+func TestListen_UDP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := pipeline.Listen("udp", "127.0.0.1:0")
+	r, err := l.Open(ctx)
+	require.NoError(t, err)
+
+	conn, err := net.Dial("udp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte(`{"message":"datagram"}`))
+	require.NoError(t, err)
+
+	line := make([]byte, 64)
+	n, err := r.Read(line)
+	require.NoError(t, err)
+	require.Equal(t, "{\"message\":\"datagram\"}\n", string(line[:n]))
+
+	cancel()
+	_, err = io.ReadAll(r)
+	require.NoError(t, err)
+}
+
+func TestListen_Error(t *testing.T) {
+	_, err := pipeline.Listen("tcp", "256.0.0.1:0").Open(context.Background())
+	require.ErrorContains(t, err, `unable to listen on tcp "256.0.0.1:0"`)
+}
@@ -0,0 +1,268 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Redacted replaces the values of redacted fields.
+const Redacted = "[REDACTED]"
+
+// Transform changes records on their way through the pipeline.
+type Transform interface {
+	// Apply returns r transformed, it may modify r's value in place.
+	Apply(r Record) Record
+}
+
+// TransformFunc adapts a function to a Transform.
+type TransformFunc func(Record) Record
+
+func (f TransformFunc) Apply(r Record) Record {
+	return f(r)
+}
+
+// Expander replaces records with any number of records, which may be none.
+type Expander interface {
+	// Expand returns the records replacing r. Along with an error, the records
+	// returned still continue down the pipeline.
+	Expand(r Record) ([]Record, error)
+}
+
+// ExpanderFunc adapts a function to an Expander.
+type ExpanderFunc func(Record) ([]Record, error)
+
+func (f ExpanderFunc) Expand(r Record) ([]Record, error) {
+	return f(r)
+}
+
+// Redact replaces the value of each of the fields with Redacted. Nested fields
+// are joined with a dot, e.g. request.headers.authorization.
+func Redact(fields ...string) Transform {
+	return TransformFunc(func(r Record) Record {
+		eachMap(r.Value, func(m map[string]any) {
+			for _, f := range fields {
+				if parent, key, ok := lookup(m, f, false); ok {
+					if _, ok := parent[key]; ok {
+						parent[key] = Redacted
+					}
+				}
+			}
+		})
+		return r
+	})
+}
+
+// Rename moves fields from each key of names to its value. Nested fields are
+// joined with a dot, the objects a new name is nested in are created as needed.
+// All fields are moved at once, so names may be swapped. A field whose new name
+// is nested in a value which is not an object is left in place. Objects left
+// empty by a move are removed.
+func Rename(names map[string]string) Transform {
+	from := make([]string, 0, len(names))
+	for k := range names {
+		from = append(from, k)
+	}
+	sort.Strings(from)
+
+	type move struct {
+		parent map[string]any
+		from   string
+		key    string
+		to     string
+		value  any
+	}
+	return TransformFunc(func(r Record) Record {
+		eachMap(r.Value, func(m map[string]any) {
+			moves := make([]move, 0, len(from))
+			for _, f := range from {
+				parent, key, ok := lookup(m, f, false)
+				if !ok {
+					continue
+				}
+				if v, ok := parent[key]; ok {
+					moves = append(moves, move{parent, f, key, names[f], v})
+				}
+			}
+			for _, mv := range moves {
+				delete(mv.parent, mv.key)
+			}
+			for _, mv := range moves {
+				if dst, key, ok := lookup(m, mv.to, true); ok {
+					dst[key] = mv.value
+				} else {
+					mv.parent[mv.key] = mv.value
+				}
+			}
+			for _, mv := range moves {
+				keys := strings.Split(mv.from, ".")
+				prune(m, keys[:len(keys)-1])
+			}
+		})
+		return r
+	})
+}
+
+// MapValues replaces the value of each field found in the field's map, keyed by
+// the value as text, e.g. {"env": {"p": "prod"}} replaces env "p" with "prod".
+// Nested fields are joined with a dot.
+func MapValues(fields map[string]map[string]string) Transform {
+	return TransformFunc(func(r Record) Record {
+		eachMap(r.Value, func(m map[string]any) {
+			for f, values := range fields {
+				parent, key, ok := lookup(m, f, false)
+				if !ok {
+					continue
+				}
+				v, ok := parent[key]
+				if !ok {
+					continue
+				}
+				if s, ok := scalarText(v); ok {
+					if to, ok := values[s]; ok {
+						parent[key] = to
+					}
+				}
+			}
+		})
+		return r
+	})
+}
+
+// Labels sets each field of labels to its template, with each {field} in the
+// template replaced by the value of the field, e.g. "{method} {path}". Nested
+// fields are joined with a dot. A label is left unset while a field it refers
+// to is missing, and labels are set in order of name, so a label may refer to
+// labels before it.
+func Labels(labels map[string]string) Transform {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	templates := make([][]string, len(names))
+	for i, name := range names {
+		templates[i] = parseTemplate(labels[name])
+	}
+	return TransformFunc(func(r Record) Record {
+		eachMap(r.Value, func(m map[string]any) {
+			for i, name := range names {
+				s, ok := expand(m, templates[i])
+				if !ok {
+					continue
+				}
+				if dst, key, ok := lookup(m, name, true); ok {
+					dst[key] = s
+				}
+			}
+		})
+		return r
+	})
+}
+
+// parseTemplate splits s into literal text at even indexes and the fields of
+// its placeholders at odd indexes. A brace without a closing brace is text.
+func parseTemplate(s string) []string {
+	var parts []string
+	for {
+		start := strings.IndexByte(s, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			break
+		}
+		parts = append(parts, s[:start], s[start+1:start+end])
+		s = s[start+end+1:]
+	}
+	return append(parts, s)
+}
+
+// expand renders the parsed template with the fields of m.
+func expand(m map[string]any, parts []string) (string, bool) {
+	var sb strings.Builder
+	for i, p := range parts {
+		if i%2 == 0 {
+			sb.WriteString(p)
+			continue
+		}
+		parent, key, ok := lookup(m, p, false)
+		if !ok {
+			return "", false
+		}
+		v, ok := parent[key]
+		if !ok {
+			return "", false
+		}
+		s, ok := scalarText(v)
+		if !ok {
+			b, err := json.Marshal(v)
+			if err != nil {
+				return "", false
+			}
+			s = string(b)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), true
+}
+
+// scalarText returns v as text, if it is not null, an object or an array.
+func scalarText(v any) (string, bool) {
+	switch vv := v.(type) {
+	case nil, map[string]any, []any:
+		return "", false
+	case string:
+		return vv, true
+	}
+	return fmt.Sprint(v), true
+}
+
+// eachMap calls fn with v if it is an object, or each object in v if it is an
+// array.
+func eachMap(v any, fn func(map[string]any)) {
+	switch vv := v.(type) {
+	case map[string]any:
+		fn(vv)
+	case []any:
+		for _, e := range vv {
+			eachMap(e, fn)
+		}
+	}
+}
+
+// prune removes the objects along keys in m which are empty, deepest first.
+func prune(m map[string]any, keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	next, ok := m[keys[0]].(map[string]any)
+	if !ok {
+		return
+	}
+	prune(next, keys[1:])
+	if len(next) == 0 {
+		delete(m, keys[0])
+	}
+}
+
+// lookup returns the object holding the dotted path in m and the path's last
+// key, creating missing objects along the way if create is set.
+func lookup(m map[string]any, path string, create bool) (map[string]any, string, bool) {
+	keys := strings.Split(path, ".")
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]any)
+		if !ok {
+			if !create || m[k] != nil {
+				return nil, "", false
+			}
+			next = make(map[string]any)
+			m[k] = next
+		}
+		m = next
+	}
+	return m, keys[len(keys)-1], true
+}
@@ -0,0 +1,117 @@
+package pipeline_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+type a = []any
+
+func TestRedact(t *testing.T) {
+	tr := pipeline.Redact("password", "req.headers.authorization", "missing.key")
+	r := tr.Apply(pipeline.Record{Value: j{
+		"password": "hunter2",
+		"req":      j{"headers": j{"authorization": "Bearer x", "accept": "*/*"}},
+		"missing":  "not an object",
+	}})
+	require.Equal(t, j{
+		"password": pipeline.Redacted,
+		"req":      j{"headers": j{"authorization": pipeline.Redacted, "accept": "*/*"}},
+		"missing":  "not an object",
+	}, r.Value)
+
+	r = tr.Apply(pipeline.Record{Value: a{j{"password": "x"}, "text"}})
+	require.Equal(t, a{j{"password": pipeline.Redacted}, "text"}, r.Value)
+
+	r = tr.Apply(pipeline.Record{Text: []byte("password=x")})
+	require.Equal(t, []byte("password=x"), r.Text)
+}
+
+func TestRename(t *testing.T) {
+	tr := pipeline.Rename(map[string]string{
+		"msg":        "message",
+		"log.level":  "level",
+		"ctx.a.msg":  "ctx.message",
+		"request_id": "req.id",
+		"absent":     "present",
+		"blocked":    "name.x",
+		"a":          "b",
+		"b":          "a",
+	})
+	r := tr.Apply(pipeline.Record{Value: j{
+		"msg":        "hello",
+		"log":        j{"level": "info"},
+		"ctx":        j{"a": j{"msg": "hi"}},
+		"empty":      j{},
+		"request_id": "abc",
+		"name":       "api",
+		"blocked":    true,
+		"a":          1,
+		"b":          2,
+	}})
+	require.Equal(t, j{
+		"message": "hello",
+		"level":   "info",
+		"ctx":     j{"message": "hi"},
+		"empty":   j{},
+		"req":     j{"id": "abc"},
+		"name":    "api",
+		"blocked": true,
+		"a":       2,
+		"b":       1,
+	}, r.Value)
+}
+
+func TestMapValues(t *testing.T) {
+	tr := pipeline.MapValues(map[string]map[string]string{
+		"env":       {"p": "prod", "s": "staging"},
+		"level":     {"30": "info", "50": "error"},
+		"ctx.ok":    {"true": "yes"},
+		"missing.x": {"a": "b"},
+	})
+	r := tr.Apply(pipeline.Record{Value: j{
+		"env":   "p",
+		"level": json.Number("50"),
+		"ctx":   j{"ok": true},
+	}})
+	require.Equal(t, j{"env": "prod", "level": "error", "ctx": j{"ok": "yes"}}, r.Value)
+
+	r = tr.Apply(pipeline.Record{Value: j{"env": "dev", "level": j{"n": "30"}}})
+	require.Equal(t, j{"env": "dev", "level": j{"n": "30"}}, r.Value)
+}
+
+func TestLabels(t *testing.T) {
+	tr := pipeline.Labels(map[string]string{
+		"route":      "{method} {path}",
+		"svc.zone":   "{region}-{az}",
+		"user":       "user {ctx}",
+		"open":       "{method",
+		"summary":    "{route} ({status})",
+		"incomplete": "{method} {missing}",
+	})
+	r := tr.Apply(pipeline.Record{Value: j{
+		"method": "GET",
+		"path":   "/api",
+		"status": json.Number("200"),
+		"region": "eu",
+		"az":     "b",
+		"ctx":    j{"id": json.Number("1")},
+	}})
+	require.Equal(t, j{
+		"method":  "GET",
+		"path":    "/api",
+		"status":  json.Number("200"),
+		"region":  "eu",
+		"az":      "b",
+		"ctx":     j{"id": json.Number("1")},
+		"route":   "GET /api",
+		"svc":     j{"zone": "eu-b"},
+		"user":    `user {"id":1}`,
+		"open":    "{method",
+		"summary": "GET /api (200)",
+	}, r.Value)
+}
@@ -0,0 +1,342 @@
+// Package plugin runs external formatter plugins, processes speaking a line
+// oriented JSON protocol over stdin and stdout.
+//
+// hz starts the command once and writes a request per record to its stdin:
+//
+//	{"record": {...}, "color": true}
+//
+// and reads a response per request, in order, from its stdout:
+//
+//	{"pin": "text", "exclude": ["key"], "record": {...}}
+//
+// Every field of the response is optional. pin is shown at the plugin's pin,
+// the excluded keys are left out of the fields and record replaces the record.
+// The plugin's stderr is passed through. A plugin which exits or does not
+// answer in time is restarted for the next record, and disabled after
+// MaxFailures failures in a row.
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/pipeline"
+)
+
+const (
+	// DefaultTimeout is the default time a plugin has to answer a request.
+	DefaultTimeout = 500 * time.Millisecond
+	// MaxFailures is how many failures in a row disable a plugin.
+	MaxFailures = 3
+
+	keyPrefix = "@plugin:"
+)
+
+var (
+	// ErrTimeout is returned when a plugin does not answer in time.
+	ErrTimeout = errors.New("timed out")
+	// ErrExited is returned when a plugin exits while answering.
+	ErrExited = errors.New("exited")
+)
+
+// Config declares a plugin in config.yml.
+type Config struct {
+	// Name is the name of the plugin's pin.
+	Name string `yaml:"name"`
+	// Command is the program to run and its arguments.
+	Command []string `yaml:"command"`
+	// Before is the pin the plugin's pin goes ahead of, message if empty.
+	Before string `yaml:"before,omitempty"`
+	// Timeout is the time the plugin has to answer, DefaultTimeout if 0.
+	Timeout time.Duration `yaml:"timeout,omitempty"`
+}
+
+// Request is sent to the plugin for each record.
+type Request struct {
+	Record map[string]any `json:"record"`
+	Color  bool           `json:"color"`
+}
+
+// Response is the plugin's answer to a Request.
+type Response struct {
+	Pin     string         `json:"pin,omitempty"`
+	Exclude []string       `json:"exclude,omitempty"`
+	Record  map[string]any `json:"record,omitempty"`
+}
+
+// Ensure we are adhering to the pipeline.Expander interface.
+var _ pipeline.Expander = (*Plugin)(nil)
+
+type Option func(*Plugin)
+
+// WithColor tells the plugin whether its pin may be colorized.
+func WithColor(b bool) Option {
+	return func(p *Plugin) {
+		p.color = b
+	}
+}
+
+// WithStderr sets where the plugin's stderr goes, stderr by default.
+func WithStderr(w io.Writer) Option {
+	return func(p *Plugin) {
+		p.stderr = w
+	}
+}
+
+// Plugin is a running plugin process.
+type Plugin struct {
+	cfg    Config
+	color  bool
+	stderr io.Writer
+
+	mu       sync.Mutex
+	proc     *process
+	failures int
+}
+
+// New returns a Plugin for cfg. The process is started with the first record.
+func New(cfg Config, opts ...Option) (*Plugin, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("plugin has no name")
+	}
+	if len(cfg.Command) == 0 {
+		return nil, fmt.Errorf("plugin %q has no command", cfg.Name)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	p := &Plugin{cfg: cfg, stderr: os.Stderr}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p, nil
+}
+
+// Name returns the name of the plugin's pin.
+func (p *Plugin) Name() string {
+	return p.cfg.Name
+}
+
+// Disabled reports whether the plugin failed too often to be called again.
+func (p *Plugin) Disabled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.failures >= MaxFailures
+}
+
+// Call sends m to the plugin and returns its response. A disabled plugin
+// returns a nil Response.
+func (p *Plugin) Call(m map[string]any) (*Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.failures >= MaxFailures {
+		return nil, nil
+	}
+
+	resp, err := p.call(m)
+	if err == nil {
+		p.failures = 0
+		return resp, nil
+	}
+	p.failures++
+	if p.failures >= MaxFailures {
+		return nil, fmt.Errorf("plugin %q disabled after %d failures: %w", p.cfg.Name, p.failures, err)
+	}
+	return nil, fmt.Errorf("plugin %q: %w", p.cfg.Name, err)
+}
+
+func (p *Plugin) call(m map[string]any) (*Response, error) {
+	req, err := json.Marshal(Request{Record: m, Color: p.color})
+	if err != nil {
+		return nil, err
+	}
+	if p.proc == nil {
+		if p.proc, err = start(p.cfg.Command, p.stderr); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := p.proc.roundTrip(append(req, '\n'), p.cfg.Timeout)
+	if err != nil {
+		// a new process answers the next record, so no answer is out of order
+		p.proc.kill()
+		p.proc = nil
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Expand calls the plugin with each object, other records pass unchanged. The
+// record is returned with the response applied, or unchanged along with an
+// error.
+func (p *Plugin) Expand(r pipeline.Record) ([]pipeline.Record, error) {
+	m, ok := r.Map()
+	if !ok {
+		return []pipeline.Record{r}, nil
+	}
+	resp, err := p.Call(m)
+	if resp == nil {
+		return []pipeline.Record{r}, err
+	}
+	if resp.Record != nil {
+		m = resp.Record
+	}
+	for _, k := range resp.Exclude {
+		delete(m, k)
+	}
+	if resp.Pin != "" {
+		m[Key(p.cfg.Name)] = resp.Pin
+	}
+	return []pipeline.Record{{Value: m}}, nil
+}
+
+// Close stops the plugin, giving it the timeout to exit once its stdin closes.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.proc == nil {
+		return nil
+	}
+	err := p.proc.close(p.cfg.Timeout)
+	p.proc = nil
+	return err
+}
+
+// Key returns the record key holding the pin text of the named plugin.
+func Key(name string) string {
+	return keyPrefix + name
+}
+
+// Formatter returns the formatter of the named plugin's pin.
+func Formatter(name string) formatter.Formatter {
+	return pin(Key(name))
+}
+
+type pin string
+
+func (p pin) Format(m map[string]any) string {
+	s, _ := m[string(p)].(string)
+	return s
+}
+
+func (p pin) ExcludeKeys() []string {
+	return []string{string(p)}
+}
+
+// process is a started plugin command.
+type process struct {
+	cmd   *exec.Cmd
+	in    io.WriteCloser
+	lines chan []byte
+	done  chan struct{}
+}
+
+func start(command []string, stderr io.Writer) (*process, error) {
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stderr = stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	p := &process{
+		cmd:   cmd,
+		in:    in,
+		lines: make(chan []byte),
+		done:  make(chan struct{}),
+	}
+	go func() {
+		defer close(p.done)
+		r := bufio.NewReader(out)
+		for {
+			line, err := r.ReadBytes('\n')
+			if len(bytes.TrimSpace(line)) > 0 {
+				p.lines <- line
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+func (p *process) roundTrip(req []byte, timeout time.Duration) (*Response, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	// write in the background so a plugin which stops reading times out
+	werr := make(chan error, 1)
+	go func() {
+		_, err := p.in.Write(req)
+		werr <- err
+	}()
+
+	for {
+		select {
+		case err := <-werr:
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrExited, err)
+			}
+			werr = nil
+		case line := <-p.lines:
+			// numbers stay json.Number, as hz decodes records
+			var resp Response
+			dec := json.NewDecoder(bytes.NewReader(line))
+			dec.UseNumber()
+			if err := dec.Decode(&resp); err != nil {
+				return nil, fmt.Errorf("invalid response: %w", err)
+			}
+			return &resp, nil
+		case <-p.done:
+			return nil, ErrExited
+		case <-timer.C:
+			return nil, fmt.Errorf("%w after %s", ErrTimeout, timeout)
+		}
+	}
+}
+
+func (p *process) kill() {
+	_ = p.cmd.Process.Kill()
+	_ = p.in.Close()
+	go p.drain()
+	_ = p.cmd.Wait()
+}
+
+// drain discards output until the reader stops, so it doesn't block.
+func (p *process) drain() {
+	for {
+		select {
+		case <-p.lines:
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *process) close(timeout time.Duration) error {
+	_ = p.in.Close()
+	go p.drain()
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		_ = p.cmd.Process.Kill()
+	}
+	return p.cmd.Wait()
+}
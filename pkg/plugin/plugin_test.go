@@ -0,0 +1,201 @@
+package plugin_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/plugin"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+
+const envMode = "HZ_TEST_PLUGIN"
+
+// TestMain runs the test binary as a plugin when HZ_TEST_PLUGIN names a mode.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(envMode); mode != "" {
+		serve(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// serve answers requests on stdin as the plugin mode does.
+func serve(mode string) {
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	for n := 1; in.Scan(); n++ {
+		var req plugin.Request
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		switch mode {
+		case "service":
+			svc, _ := req.Record["svc"].(string)
+			pin := strings.ToUpper(svc)
+			if req.Color {
+				pin = "\x1b[1m" + pin + "\x1b[0m"
+			}
+			_ = out.Encode(plugin.Response{Pin: pin, Exclude: []string{"svc"}})
+		case "replace":
+			_ = out.Encode(plugin.Response{Record: j{"message": fmt.Sprintf("record %d", n)}})
+		case "numbers":
+			fmt.Println(`{"record": {"time": 1700000000, "id": 12345678901234567890}}`)
+		case "crash":
+			if req.Record["crash"] == true {
+				os.Exit(2)
+			}
+			_ = out.Encode(plugin.Response{Pin: fmt.Sprint(n)})
+		case "hang":
+			if req.Record["hang"] == true {
+				time.Sleep(time.Minute)
+			}
+			_ = out.Encode(plugin.Response{Pin: fmt.Sprint(n)})
+		case "garbage":
+			fmt.Println("not json")
+		}
+	}
+}
+
+func newPlugin(t *testing.T, mode string, opts ...plugin.Option) *plugin.Plugin {
+	t.Helper()
+	t.Setenv(envMode, mode)
+	p, err := plugin.New(plugin.Config{
+		Name:    "svc",
+		Command: []string{os.Args[0]},
+		Timeout: time.Second,
+	}, append([]plugin.Option{plugin.WithStderr(io.Discard)}, opts...)...)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func expand(t *testing.T, p *plugin.Plugin, m j) (j, error) {
+	t.Helper()
+	records, err := p.Expand(pipeline.Record{Value: m})
+	require.Len(t, records, 1)
+	out, ok := records[0].Map()
+	require.True(t, ok)
+	return out, err
+}
+
+func TestNew(t *testing.T) {
+	_, err := plugin.New(plugin.Config{Command: []string{"cat"}})
+	require.EqualError(t, err, "plugin has no name")
+
+	_, err = plugin.New(plugin.Config{Name: "svc"})
+	require.EqualError(t, err, `plugin "svc" has no command`)
+}
+
+func TestExpand(t *testing.T) {
+	p := newPlugin(t, "service")
+	for _, svc := range []string{"api", "db"} {
+		m, err := expand(t, p, j{"message": "started", "svc": svc})
+		require.NoError(t, err)
+		require.Equal(t, j{"message": "started", plugin.Key("svc"): strings.ToUpper(svc)}, m)
+	}
+}
+
+func TestExpand_Color(t *testing.T) {
+	p := newPlugin(t, "service", plugin.WithColor(true))
+	m, err := expand(t, p, j{"svc": "api"})
+	require.NoError(t, err)
+	require.Equal(t, "\x1b[1mAPI\x1b[0m", m[plugin.Key("svc")])
+}
+
+func TestExpand_Record(t *testing.T) {
+	p := newPlugin(t, "replace")
+	m, err := expand(t, p, j{"message": "original"})
+	require.NoError(t, err)
+	require.Equal(t, j{"message": "record 1"}, m)
+}
+
+func TestExpand_Numbers(t *testing.T) {
+	p := newPlugin(t, "numbers")
+	m, err := expand(t, p, j{"message": "original"})
+	require.NoError(t, err)
+	require.Equal(t, j{"time": json.Number("1700000000"), "id": json.Number("12345678901234567890")}, m)
+}
+
+func TestExpand_Text(t *testing.T) {
+	p := newPlugin(t, "garbage")
+	r := pipeline.Record{Text: []byte("plain text\n")}
+	records, err := p.Expand(r)
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{r}, records)
+}
+
+func TestExpand_Crash(t *testing.T) {
+	p := newPlugin(t, "crash")
+	m, err := expand(t, p, j{"n": 1})
+	require.NoError(t, err)
+	require.Equal(t, "1", m[plugin.Key("svc")])
+
+	// the record passes unchanged and the plugin restarts for the next one
+	m, err = expand(t, p, j{"crash": true})
+	require.ErrorIs(t, err, plugin.ErrExited)
+	require.Equal(t, j{"crash": true}, m)
+
+	m, err = expand(t, p, j{"n": 3})
+	require.NoError(t, err)
+	require.Equal(t, "1", m[plugin.Key("svc")])
+}
+
+func TestExpand_Timeout(t *testing.T) {
+	t.Setenv(envMode, "hang")
+	p, err := plugin.New(plugin.Config{
+		Name:    "svc",
+		Command: []string{os.Args[0]},
+		Timeout: 100 * time.Millisecond,
+	}, plugin.WithStderr(io.Discard))
+	require.NoError(t, err)
+	defer p.Close()
+
+	m, err := expand(t, p, j{"hang": true})
+	require.ErrorIs(t, err, plugin.ErrTimeout)
+	require.Equal(t, j{"hang": true}, m)
+
+	m, err = expand(t, p, j{})
+	require.NoError(t, err)
+	require.Equal(t, "1", m[plugin.Key("svc")])
+}
+
+func TestExpand_Disabled(t *testing.T) {
+	p := newPlugin(t, "garbage")
+	for i := 1; i < plugin.MaxFailures; i++ {
+		_, err := expand(t, p, j{})
+		require.ErrorContains(t, err, `plugin "svc": invalid response`)
+		require.False(t, p.Disabled())
+	}
+	_, err := expand(t, p, j{})
+	require.ErrorContains(t, err, `plugin "svc" disabled after 3 failures`)
+	require.True(t, p.Disabled())
+
+	// once disabled records pass through without an error
+	m, err := expand(t, p, j{"message": "hi"})
+	require.NoError(t, err)
+	require.Equal(t, j{"message": "hi"}, m)
+}
+
+func TestExpand_NotFound(t *testing.T) {
+	p, err := plugin.New(plugin.Config{Name: "svc", Command: []string{"hz-no-such-plugin"}})
+	require.NoError(t, err)
+	_, err = expand(t, p, j{})
+	require.ErrorContains(t, err, `plugin "svc": exec: "hz-no-such-plugin"`)
+}
+
+func TestFormatter(t *testing.T) {
+	f := plugin.Formatter("svc")
+	require.Equal(t, "API", f.Format(j{plugin.Key("svc"): "API"}))
+	require.Equal(t, "", f.Format(j{}))
+	require.Equal(t, []string{plugin.Key("svc")}, f.ExcludeKeys())
+}
@@ -0,0 +1,358 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type kind int
+
+const (
+	tokEOF    kind = iota
+	tokDot         // .
+	tokField       // .name
+	tokIdent       // name
+	tokString      // "text"
+	tokNumber      // 1, -2.5
+	tokPunct       // one of []{}():,|?
+)
+
+const punct = "[]{}():,|?"
+
+type token struct {
+	kind kind
+	// text is the name of a field or identifier, the decoded string or the
+	// source of other tokens
+	text string
+	// pos is the byte offset of the token in the source
+	pos int
+}
+
+func (t token) is(p string) bool {
+	return t.kind == tokPunct && t.text == p
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokField:
+		return strconv.Quote("." + t.text)
+	case tokString:
+		return strconv.Quote(strconv.Quote(t.text))
+	}
+	return strconv.Quote(t.text)
+}
+
+// SyntaxError is a problem found at a position in a query.
+type SyntaxError struct {
+	// Column is the 1-based column the problem was found at.
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+func errorAt(pos int, format string, a ...any) error {
+	return &SyntaxError{Column: pos + 1, Msg: fmt.Sprintf(format, a...)}
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '.':
+			j := i + 1
+			for j < len(src) && isIdent(src[j], j > i+1) {
+				j++
+			}
+			if j > i+1 {
+				toks = append(toks, token{tokField, src[i+1 : j], i})
+			} else {
+				toks = append(toks, token{tokDot, ".", i})
+			}
+			i = j
+		case isIdent(c, false):
+			j := i + 1
+			for j < len(src) && isIdent(src[j], true) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		case c == '"':
+			j := i + 1
+			for j < len(src) && src[j] != '"' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, errorAt(i, "unterminated string")
+			}
+			var s string
+			if err := json.Unmarshal([]byte(src[i:j+1]), &s); err != nil {
+				return nil, errorAt(i, "invalid string %s", src[i:j+1])
+			}
+			toks = append(toks, token{tokString, s, i})
+			i = j + 1
+		case isDigit(c) || c == '-' && i+1 < len(src) && isDigit(src[i+1]):
+			j := i + 1
+			for j < len(src) && (isDigit(src[j]) || strings.IndexByte(".eE+-", src[j]) >= 0) {
+				j++
+			}
+			if _, err := strconv.ParseFloat(src[i:j], 64); err != nil {
+				return nil, errorAt(i, "invalid number %q", src[i:j])
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case strings.IndexByte(punct, c) >= 0:
+			toks = append(toks, token{tokPunct, string(c), i})
+			i++
+		default:
+			return nil, errorAt(i, "unexpected %q", c)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+func isIdent(c byte, digits bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || digits && isDigit(c)
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// parser is a recursive descent parser of the grammar:
+//
+//	pipe    = comma { "|" comma }
+//	comma   = term { "," term }
+//	term    = primary { suffix }
+//	primary = "." | field | "." string | "{" [ entry { "," entry } ] "}"
+//	        | "[" [ pipe ] "]" | "(" pipe ")" | string | number | true | false | null
+//	suffix  = field | "." string | [ "." ] "[" [ string | number ] "]" | "?"
+//	entry   = ( ident | string ) [ ":" term { "|" term } ]
+type parser struct {
+	toks []token
+	i    int
+}
+
+func newParser(src string) (*parser, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	return &parser{toks: toks}, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.i]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) expect(punct string) error {
+	if t := p.next(); !t.is(punct) {
+		return errorAt(t.pos, "expected %q, got %s", punct, t)
+	}
+	return nil
+}
+
+func (p *parser) parse() (node, error) {
+	if p.peek().kind == tokEOF {
+		return nil, errorAt(0, "empty query")
+	}
+	n, err := p.pipe()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorAt(t.pos, "unexpected %s", t)
+	}
+	return n, nil
+}
+
+func (p *parser) pipe() (node, error) {
+	left, err := p.comma()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is("|") {
+		p.next()
+		right, err := p.comma()
+		if err != nil {
+			return nil, err
+		}
+		left = pipe{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) comma() (node, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().is(",") {
+		p.next()
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = comma{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) term() (node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokField:
+			p.next()
+			n = index{n, t.text}
+		case t.kind == tokDot && p.toks[p.i+1].kind == tokString:
+			p.next()
+			n = index{n, p.next().text}
+		case t.kind == tokDot && p.toks[p.i+1].is("["):
+			p.next()
+		case t.is("["):
+			if n, err = p.bracket(n); err != nil {
+				return nil, err
+			}
+		case t.is("?"):
+			p.next()
+			n = optional{n}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch {
+	case t.kind == tokDot:
+		if n := p.peek(); (n.kind == tokDot || n.kind == tokField) && n.pos == t.pos+1 {
+			return nil, errorAt(t.pos, "recursive descent \"..\" is not supported")
+		}
+		if p.peek().kind == tokString {
+			return index{identity{}, p.next().text}, nil
+		}
+		return identity{}, nil
+	case t.kind == tokField:
+		return index{identity{}, t.text}, nil
+	case t.kind == tokString:
+		return literal{t.text}, nil
+	case t.kind == tokNumber:
+		return literal{json.Number(t.text)}, nil
+	case t.kind == tokIdent:
+		switch t.text {
+		case "true":
+			return literal{true}, nil
+		case "false":
+			return literal{false}, nil
+		case "null":
+			return literal{nil}, nil
+		}
+		return nil, errorAt(t.pos, "unknown function %q", t.text)
+	case t.is("("):
+		n, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return n, p.expect(")")
+	case t.is("["):
+		if p.peek().is("]") {
+			p.next()
+			return array{}, nil
+		}
+		n, err := p.pipe()
+		if err != nil {
+			return nil, err
+		}
+		return array{n}, p.expect("]")
+	case t.is("{"):
+		return p.object()
+	}
+	return nil, errorAt(t.pos, "unexpected %s", t)
+}
+
+// bracket parses an index or iteration of base.
+func (p *parser) bracket(base node) (node, error) {
+	p.next()
+	t := p.next()
+	switch t.kind {
+	case tokPunct:
+		if t.is("]") {
+			return iterate{base}, nil
+		}
+	case tokString:
+		return index{base, t.text}, p.expect("]")
+	case tokNumber:
+		i, err := strconv.Atoi(t.text)
+		if err != nil {
+			return nil, errorAt(t.pos, "index %s is not an integer", t.text)
+		}
+		return index{base, i}, p.expect("]")
+	}
+	return nil, errorAt(t.pos, "expected a string, number or \"]\", got %s", t)
+}
+
+func (p *parser) object() (node, error) {
+	var n object
+	if p.peek().is("}") {
+		p.next()
+		return n, nil
+	}
+	for {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokString {
+			return nil, errorAt(t.pos, "expected a key, got %s", t)
+		}
+		e := entry{key: t.text, value: index{identity{}, t.text}}
+		if p.peek().is(":") {
+			p.next()
+			value, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			for p.peek().is("|") {
+				p.next()
+				right, err := p.term()
+				if err != nil {
+					return nil, err
+				}
+				value = pipe{value, right}
+			}
+			e.value = value
+		}
+		n.entries = append(n.entries, e)
+
+		t = p.next()
+		if t.is("}") {
+			return n, nil
+		}
+		if !t.is(",") {
+			return nil, errorAt(t.pos, "expected \",\" or \"}\", got %s", t)
+		}
+	}
+}
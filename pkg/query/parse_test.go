@@ -0,0 +1,36 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse_Error(t *testing.T) {
+	testcases := map[string]string{
+		``:               `column 1: empty query`,
+		`message`:        `column 1: unknown function "message"`,
+		`.a b`:           `column 4: unexpected "b"`,
+		`.a |`:           `column 5: unexpected end of query`,
+		`..a`:            `column 1: recursive descent ".." is not supported`,
+		`.a[`:            `column 4: expected a string, number or "]", got end of query`,
+		`.a[1.5]`:        `column 4: index 1.5 is not an integer`,
+		`.a["x"`:         `column 7: expected "]", got end of query`,
+		`.a["x`:          `column 4: unterminated string`,
+		`.a["\q"]`:       `column 4: invalid string "\q"`,
+		`{msg: .message`: `column 15: expected "," or "}", got end of query`,
+		`{.message}`:     `column 2: expected a key, got ".message"`,
+		`(.a`:            `column 4: expected ")", got end of query`,
+		`.a & .b`:        `column 4: unexpected '&'`,
+		`1e`:             `column 1: invalid number "1e"`,
+	}
+	for src, want := range testcases {
+		t.Run(src, func(t *testing.T) {
+			_, err := query.Parse(src)
+			require.EqualError(t, err, want)
+			var serr *query.SyntaxError
+			require.ErrorAs(t, err, &serr)
+		})
+	}
+}
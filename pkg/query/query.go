@@ -0,0 +1,282 @@
+// Package query selects and reshapes values with a subset of the jq language:
+//
+//	.                   the value itself
+//	.foo, ."foo bar"    the value of a key, null if it is missing
+//	.["foo"], .[0]      the value of a key or the element at an index,
+//	                    negative indexes count from the end
+//	.[]                 each element of an array or value of an object
+//	{msg: .message, id} an object, id is short for id: .id
+//	[.a, .b]            an array of every result
+//	.a, .b              the results of .a followed by those of .b
+//	.items[] | .id      each result of .items[] passed through .id
+//	?                   suffix ignoring errors, e.g. .items[]?
+//
+// along with string, number, true, false and null literals and parentheses.
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+)
+
+// Ensure we are adhering to the pipeline.Expander interface.
+var _ pipeline.Expander = (*Query)(nil)
+
+// Query is a parsed query.
+type Query struct {
+	src  string
+	root node
+}
+
+// Parse parses src.
+func Parse(src string) (*Query, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+// String returns the source of q.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Eval returns the results of q applied to v, which is a value as decoded from
+// JSON.
+func (q *Query) Eval(v any) ([]any, error) {
+	return q.root.eval(v)
+}
+
+// Expand replaces each value with the results of q which are not null, so a
+// value q has no results for is dropped. A value q fails on passes unchanged
+// along with the error, as does text.
+func (q *Query) Expand(r pipeline.Record) ([]pipeline.Record, error) {
+	if r.IsText() {
+		return []pipeline.Record{r}, nil
+	}
+	values, err := q.Eval(r.Value)
+	if err != nil {
+		return []pipeline.Record{r}, fmt.Errorf("select %s: %w", q.src, err)
+	}
+	records := make([]pipeline.Record, 0, len(values))
+	for _, v := range values {
+		if v != nil {
+			records = append(records, pipeline.Record{Value: v})
+		}
+	}
+	return records, nil
+}
+
+type node interface {
+	eval(v any) ([]any, error)
+}
+
+// each evaluates n with v and calls fn with each result, collecting the
+// results of fn.
+func each(n node, v any, fn func(any) ([]any, error)) ([]any, error) {
+	values, err := n.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	var out []any
+	for _, e := range values {
+		r, err := fn(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, r...)
+	}
+	return out, nil
+}
+
+type identity struct{}
+
+func (identity) eval(v any) ([]any, error) {
+	return []any{v}, nil
+}
+
+type literal struct {
+	v any
+}
+
+func (n literal) eval(any) ([]any, error) {
+	return []any{n.v}, nil
+}
+
+// index looks up a key, if key is a string, or an index, if key is an int.
+type index struct {
+	base node
+	key  any
+}
+
+func (n index) eval(v any) ([]any, error) {
+	return each(n.base, v, func(v any) ([]any, error) {
+		switch vv := v.(type) {
+		case nil:
+			return []any{nil}, nil
+		case map[string]any:
+			if k, ok := n.key.(string); ok {
+				return []any{vv[k]}, nil
+			}
+		case []any:
+			if i, ok := n.key.(int); ok {
+				if i < 0 {
+					i += len(vv)
+				}
+				if i < 0 || i >= len(vv) {
+					return []any{nil}, nil
+				}
+				return []any{vv[i]}, nil
+			}
+		}
+		if k, ok := n.key.(string); ok {
+			return nil, fmt.Errorf("cannot index %s with %q", typeName(v), k)
+		}
+		return nil, fmt.Errorf("cannot index %s with number", typeName(v))
+	})
+}
+
+type iterate struct {
+	base node
+}
+
+func (n iterate) eval(v any) ([]any, error) {
+	return each(n.base, v, func(v any) ([]any, error) {
+		switch vv := v.(type) {
+		case []any:
+			return vv, nil
+		case map[string]any:
+			keys := make([]string, 0, len(vv))
+			for k := range vv {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			out := make([]any, len(keys))
+			for i, k := range keys {
+				out[i] = vv[k]
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", typeName(v))
+	})
+}
+
+// optional drops the error of base, along with its results.
+type optional struct {
+	base node
+}
+
+func (n optional) eval(v any) ([]any, error) {
+	out, err := n.base.eval(v)
+	if err != nil {
+		return nil, nil
+	}
+	return out, nil
+}
+
+type pipe struct {
+	left, right node
+}
+
+func (n pipe) eval(v any) ([]any, error) {
+	return each(n.left, v, n.right.eval)
+}
+
+type comma struct {
+	left, right node
+}
+
+func (n comma) eval(v any) ([]any, error) {
+	left, err := n.left.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(left, right...), nil
+}
+
+type array struct {
+	// body is nil for an empty array
+	body node
+}
+
+func (n array) eval(v any) ([]any, error) {
+	if n.body == nil {
+		return []any{[]any{}}, nil
+	}
+	values, err := n.body.eval(v)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = []any{}
+	}
+	return []any{values}, nil
+}
+
+type entry struct {
+	key   string
+	value node
+}
+
+// object builds an object per combination of the results of its values.
+type object struct {
+	entries []entry
+}
+
+func (n object) eval(v any) ([]any, error) {
+	out := []map[string]any{{}}
+	for _, e := range n.entries {
+		values, err := e.value.eval(v)
+		if err != nil {
+			return nil, err
+		}
+		next := make([]map[string]any, 0, len(out)*len(values))
+		for _, m := range out {
+			for _, value := range values {
+				c := make(map[string]any, len(m)+1)
+				for k, v := range m {
+					c[k] = v
+				}
+				c[e.key] = value
+				next = append(next, c)
+			}
+		}
+		out = next
+	}
+	values := make([]any, len(out))
+	for i, m := range out {
+		values[i] = m
+	}
+	return values, nil
+}
+
+// typeName returns the JSON type of v.
+func typeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64, float32, int, int64, int32, uint, uint64, uint32:
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
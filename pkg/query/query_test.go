@@ -0,0 +1,141 @@
+package query_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/query"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+type a = []any
+
+const record = `{
+	"level": "info",
+	"message": "request",
+	"request": {"method": "GET", "headers": {"user-agent": "curl/8.0"}},
+	"ctx": {"user": {"id": 42}},
+	"items": [{"id": "a"}, {"id": "b"}, {"name": "c"}],
+	"tags": {"env": "prod", "az": "b"}
+}`
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var v any
+	require.NoError(t, dec.Decode(&v))
+	return v
+}
+
+func TestEval(t *testing.T) {
+	testcases := map[string]struct {
+		query string
+		want  []any
+	}{
+		"identity":       {`.message | .`, a{"request"}},
+		"field":          {`.message`, a{"request"}},
+		"nested":         {`.ctx.user.id`, a{json.Number("42")}},
+		"missing":        {`.nope.deeper`, a{nil}},
+		"quoted":         {`.request.headers["user-agent"]`, a{"curl/8.0"}},
+		"dot quoted":     {`.request.headers."user-agent"`, a{"curl/8.0"}},
+		"dot bracket":    {`.items.[1].id`, a{"b"}},
+		"index":          {`.items[0].id`, a{"a"}},
+		"negative":       {`.items[-1].name`, a{"c"}},
+		"out of range":   {`.items[5]`, a{nil}},
+		"iterate":        {`.items[].id`, a{"a", "b", nil}},
+		"iterate object": {`.tags[]`, a{"b", "prod"}},
+		"object": {`{msg: .message, user: .ctx.user.id}`, a{
+			j{"msg": "request", "user": json.Number("42")},
+		}},
+		"shorthand":    {`{level, "message"}`, a{j{"level": "info", "message": "request"}}},
+		"empty object": {`{}`, a{j{}}},
+		"object product": {`{id: .items[].id, level}`, a{
+			j{"id": "a", "level": "info"},
+			j{"id": "b", "level": "info"},
+			j{"id": nil, "level": "info"},
+		}},
+		"object pipe": {`{method: .request | .method}`, a{j{"method": "GET"}}},
+		"array":       {`[.items[].id]`, a{a{"a", "b", nil}}},
+		"empty array": {`[]`, a{a{}}},
+		"comma":       {`.level, .message`, a{"info", "request"}},
+		"pipe":        {`.items[] | {id}`, a{j{"id": "a"}, j{"id": "b"}, j{"id": nil}}},
+		"parens":      {`(.ctx | .user).id`, a{json.Number("42")}},
+		"literals": {`{s: "x", n: -1.5, t: true, f: false, z: null}`, a{
+			j{"s": "x", "n": json.Number("-1.5"), "t": true, "f": false, "z": nil},
+		}},
+		"optional": {`.message[]?`, nil},
+	}
+	v := decode(t, record)
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			q, err := query.Parse(tc.query)
+			require.NoError(t, err)
+			got, err := q.Eval(v)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestEval_Error(t *testing.T) {
+	testcases := map[string]string{
+		`.message.text`: `cannot index string with "text"`,
+		`.items.id`:     `cannot index array with "id"`,
+		`.tags[0]`:      `cannot index object with number`,
+		`.nope[]`:       `cannot iterate over null`,
+		`.level[]`:      `cannot iterate over string`,
+	}
+	v := decode(t, record)
+	for src, want := range testcases {
+		t.Run(src, func(t *testing.T) {
+			q, err := query.Parse(src)
+			require.NoError(t, err)
+			_, err = q.Eval(v)
+			require.EqualError(t, err, want)
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	q, err := query.Parse(`.items[] | {id, level: "info"}`)
+	require.NoError(t, err)
+
+	records, err := q.Expand(pipeline.Record{Value: decode(t, record)})
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{
+		{Value: j{"id": "a", "level": "info"}},
+		{Value: j{"id": "b", "level": "info"}},
+		{Value: j{"id": nil, "level": "info"}},
+	}, records)
+
+	text := pipeline.Record{Text: []byte("plain text\n")}
+	records, err = q.Expand(text)
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{text}, records)
+
+	// values the query fails on pass unchanged along with the error
+	failed := pipeline.Record{Value: j{"items": "none"}}
+	records, err = q.Expand(failed)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "select .items[] | {id, level: \"info\"}: ")
+	require.Equal(t, []pipeline.Record{failed}, records)
+}
+
+func TestExpand_Null(t *testing.T) {
+	q, err := query.Parse(`.items[].id`)
+	require.NoError(t, err)
+
+	records, err := q.Expand(pipeline.Record{Value: decode(t, record)})
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{{Value: "a"}, {Value: "b"}}, records)
+}
+
+func TestString(t *testing.T) {
+	q, err := query.Parse(`{msg: .message}`)
+	require.NoError(t, err)
+	require.Equal(t, `{msg: .message}`, q.String())
+}
@@ -0,0 +1,54 @@
+package schema
+
+import (
+	"fmt"
+
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+// ecsMoves are the fields FromECS moves, in order. Error details move ahead of
+// error.message so the error object is left empty for the message.
+var ecsMoves = []struct {
+	path string
+	key  string
+}{
+	{"log.level", formatter.KeyLevel},
+	{"log.logger", KeyLogger},
+	{"error.type", "error_type"},
+	{"error.code", "error_code"},
+	{"error.stack_trace", "stack_trace"},
+	{"error.message", formatter.KeyError},
+	{"service.name", KeyService},
+	{"trace.id", KeyTraceID},
+	{"span.id", KeySpanID},
+}
+
+// IsECS reports whether m looks like an Elastic Common Schema record: it has
+// an ecs.version, or a log.level along with an @timestamp.
+func IsECS(m map[string]any) bool {
+	if has(m, "ecs.version") {
+		return true
+	}
+	_, ok := m[formatter.KeyAtTimestamp]
+	return ok && has(m, "log.level")
+}
+
+// FromECS maps the Elastic Common Schema fields of m onto the keys hz pins, in
+// place. Fields are found as dotted keys, e.g. "log.level", or nested in
+// objects, and fields whose key is already set elsewhere are left in place.
+func FromECS(m map[string]any) {
+	_, _ = take(m, "ecs.version")
+	for _, mv := range ecsMoves {
+		move(m, mv.path, mv.key)
+	}
+
+	if _, ok := m[formatter.KeyCaller]; ok || !has(m, "log.origin.file.name") {
+		return
+	}
+	file, _ := take(m, "log.origin.file.name")
+	caller := fmt.Sprint(file)
+	if line, ok := take(m, "log.origin.file.line"); ok {
+		caller += ":" + fmt.Sprint(line)
+	}
+	m[formatter.KeyCaller] = caller
+}
@@ -0,0 +1,99 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/schema"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+
+func TestIsECS(t *testing.T) {
+	require.True(t, schema.IsECS(j{"ecs.version": "1.6.0"}))
+	require.True(t, schema.IsECS(j{"ecs": j{"version": "8.11"}}))
+	require.True(t, schema.IsECS(j{"@timestamp": "2022-08-03T12:34:20Z", "log": j{"level": "info"}}))
+	require.False(t, schema.IsECS(j{"log": j{"level": "info"}}))
+	require.False(t, schema.IsECS(j{"level": "info", "message": "hi"}))
+}
+
+func TestFromECS(t *testing.T) {
+	testcases := map[string]struct {
+		in   j
+		want j
+	}{
+		"dotted": {
+			in: j{
+				"@timestamp":           "2022-08-03T12:34:20Z",
+				"ecs.version":          "1.6.0",
+				"log.level":            "error",
+				"log.logger":           "http",
+				"log.origin.file.name": "server.go",
+				"log.origin.file.line": json.Number("42"),
+				"message":              "request failed",
+				"error.message":        "connection refused",
+				"error.type":           "net.OpError",
+				"service.name":         "api",
+				"trace.id":             "4bf92f35",
+				"span.id":              "00f067aa",
+			},
+			want: j{
+				"@timestamp": "2022-08-03T12:34:20Z",
+				"level":      "error",
+				"logger":     "http",
+				"caller":     "server.go:42",
+				"message":    "request failed",
+				"error":      "connection refused",
+				"error_type": "net.OpError",
+				"service":    "api",
+				"trace_id":   "4bf92f35",
+				"span_id":    "00f067aa",
+			},
+		},
+		"nested": {
+			in: j{
+				"@timestamp": "2022-08-03T12:34:20Z",
+				"ecs":        j{"version": "8.11"},
+				"log": j{
+					"level":  "warn",
+					"origin": j{"file": j{"name": "server.go"}, "function": "serve"},
+				},
+				"message": "slow",
+				"error":   j{"message": "timeout", "stack_trace": "goroutine 1"},
+			},
+			want: j{
+				"@timestamp":  "2022-08-03T12:34:20Z",
+				"level":       "warn",
+				"caller":      "server.go",
+				"log":         j{"origin": j{"function": "serve"}},
+				"message":     "slow",
+				"error":       "timeout",
+				"stack_trace": "goroutine 1",
+			},
+		},
+		"taken": {
+			// keys already set and objects holding more are left in place
+			in: j{
+				"level":                "info",
+				"log":                  j{"level": "warn"},
+				"service":              j{"name": "api", "version": "1.2.3"},
+				"caller":               "main.go:1",
+				"log.origin.file.name": "server.go",
+			},
+			want: j{
+				"level":                "info",
+				"log":                  j{"level": "warn"},
+				"service":              j{"name": "api", "version": "1.2.3"},
+				"caller":               "main.go:1",
+				"log.origin.file.name": "server.go",
+			},
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			schema.FromECS(tc.in)
+			require.Equal(t, tc.want, tc.in)
+		})
+	}
+}
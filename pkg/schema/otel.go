@@ -0,0 +1,229 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+const (
+	keyResourceLogs = "resourceLogs"
+	keyScopeLogs    = "scopeLogs"
+	keyLogRecords   = "logRecords"
+	keyResource     = "resource"
+	keyScope        = "scope"
+	keyAttributes   = "attributes"
+
+	keyServiceName = "service.name"
+)
+
+// otelDropped are the fields of a log record FromOTel leaves out, along with
+// those it maps.
+var otelDropped = []string{"observedTimeUnixNano", "flags", "droppedAttributesCount"}
+
+// IsOTel reports whether m looks like an OpenTelemetry log record, or an OTLP
+// JSON export of log records.
+func IsOTel(m map[string]any) bool {
+	if _, ok := m[keyResourceLogs]; ok {
+		return true
+	}
+	_, text := m["severityText"]
+	_, number := m["severityNumber"]
+	_, body := m["body"]
+	_, ts := m["timeUnixNano"]
+	return (text || number) && (body || ts)
+}
+
+// FromOTel returns the records of m, an OTLP JSON export whose log records are
+// nested in resourceLogs and scopeLogs, or a single log record. Each record
+// holds the attributes of the log record, with the time, level, message,
+// trace_id and span_id set from the log record, service from the resource's
+// service.name, resource from its other attributes and logger from the name
+// of the scope. A record which is not an export or log record is returned as
+// is.
+func FromOTel(m map[string]any) []map[string]any {
+	resourceLogs, ok := m[keyResourceLogs].([]any)
+	if !ok {
+		if !IsOTel(m) {
+			return []map[string]any{m}
+		}
+		res, _ := m[keyResource].(map[string]any)
+		scope, _ := m[keyScope].(map[string]any)
+		return []map[string]any{logRecord(m, res, scope)}
+	}
+
+	var out []map[string]any
+	for _, rl := range objects(resourceLogs) {
+		res, _ := rl[keyResource].(map[string]any)
+		scopeLogs, _ := rl[keyScopeLogs].([]any)
+		for _, sl := range objects(scopeLogs) {
+			scope, _ := sl[keyScope].(map[string]any)
+			records, _ := sl[keyLogRecords].([]any)
+			for _, lr := range objects(records) {
+				out = append(out, logRecord(lr, res, scope))
+			}
+		}
+	}
+	return out
+}
+
+func objects(a []any) []map[string]any {
+	out := make([]map[string]any, 0, len(a))
+	for _, e := range a {
+		if m, ok := e.(map[string]any); ok {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// logRecord maps the log record lr of the resource res and scope onto a record.
+func logRecord(lr map[string]any, res map[string]any, scope map[string]any) map[string]any {
+	m := attributes(lr[keyAttributes])
+
+	var resAttrs any = res
+	if a, ok := res[keyAttributes]; ok {
+		resAttrs = a
+	}
+	if attrs := attributes(resAttrs); len(attrs) > 0 {
+		if name, ok := attrs[keyServiceName]; ok {
+			m[KeyService] = name
+			delete(attrs, keyServiceName)
+		}
+		if len(attrs) > 0 {
+			m[keyResource] = attrs
+		}
+	}
+	if name, ok := scope["name"].(string); ok && name != "" {
+		m[KeyLogger] = name
+	}
+
+	mapped := map[string]bool{keyAttributes: true, keyResource: true, keyScope: true}
+	set := func(key string, from string, v any) {
+		m[key] = v
+		mapped[from] = true
+	}
+	if t, ok := unixNano(lr["timeUnixNano"]); ok {
+		set(formatter.KeyTime, "timeUnixNano", t)
+	} else if t, ok := unixNano(lr["observedTimeUnixNano"]); ok {
+		set(formatter.KeyTime, "observedTimeUnixNano", t)
+	}
+	if l := severity(integer(lr["severityNumber"])); l != "" {
+		set(formatter.KeyLevel, "severityNumber", l)
+		mapped["severityText"] = true
+	} else if l, ok := lr["severityText"].(string); ok && l != "" {
+		set(formatter.KeyLevel, "severityText", strings.ToLower(l))
+	}
+	if body, ok := lr["body"]; ok {
+		switch v := anyValue(body).(type) {
+		case string:
+			set(formatter.KeyMessage, "body", v)
+		default:
+			set("body", "body", v)
+		}
+	}
+	if id, ok := lr["traceId"].(string); ok && id != "" {
+		set(KeyTraceID, "traceId", id)
+	}
+	if id, ok := lr["spanId"].(string); ok && id != "" {
+		set(KeySpanID, "spanId", id)
+	}
+	for _, k := range otelDropped {
+		mapped[k] = true
+	}
+
+	// keep anything else, e.g. fields a logger added to the record
+	for k, v := range lr {
+		if !mapped[k] {
+			if _, ok := m[k]; !ok {
+				m[k] = v
+			}
+		}
+	}
+	return m
+}
+
+// attributes decodes the attributes of OTLP JSON, a list of key and value
+// objects, or an object of attributes as a log record may hold.
+func attributes(v any) map[string]any {
+	m := make(map[string]any)
+	switch vv := v.(type) {
+	case []any:
+		for _, kv := range objects(vv) {
+			if k, ok := kv["key"].(string); ok {
+				m[k] = anyValue(kv["value"])
+			}
+		}
+	case map[string]any:
+		for k, v := range vv {
+			m[k] = anyValue(v)
+		}
+	}
+	return m
+}
+
+// anyValue decodes an OTLP JSON AnyValue, e.g. {"stringValue": "text"}. Other
+// values are returned unchanged.
+func anyValue(v any) any {
+	m, ok := v.(map[string]any)
+	if !ok || len(m) != 1 {
+		return v
+	}
+	for k, e := range m {
+		switch k {
+		case "stringValue", "boolValue", "bytesValue":
+			return e
+		case "intValue", "doubleValue":
+			// 64 bit integers are encoded as strings
+			if s, ok := e.(string); ok {
+				return json.Number(s)
+			}
+			return e
+		case "arrayValue":
+			list, _ := e.(map[string]any)
+			values, _ := list["values"].([]any)
+			out := make([]any, len(values))
+			for i, v := range values {
+				out[i] = anyValue(v)
+			}
+			return out
+		case "kvlistValue":
+			list, _ := e.(map[string]any)
+			return attributes(list["values"])
+		}
+	}
+	return v
+}
+
+// unixNano formats a time in nanoseconds since the epoch, as a number or a
+// string of one.
+func unixNano(v any) (string, bool) {
+	n := integer(v)
+	if n <= 0 {
+		return "", false
+	}
+	return time.Unix(0, n).UTC().Format(time.RFC3339Nano), true
+}
+
+func integer(v any) int64 {
+	switch vv := v.(type) {
+	case json.Number:
+		n, _ := strconv.ParseInt(vv.String(), 10, 64)
+		return n
+	case string:
+		n, _ := strconv.ParseInt(vv, 10, 64)
+		return n
+	case float64:
+		return int64(vv)
+	case int64:
+		return vv
+	case int:
+		return int64(vv)
+	}
+	n, _ := strconv.ParseInt(fmt.Sprint(v), 10, 64)
+	return n
+}
@@ -0,0 +1,112 @@
+package schema_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/schema"
+	"github.com/stretchr/testify/require"
+)
+
+const export = `{"resourceLogs": [{
+	"resource": {"attributes": [
+		{"key": "service.name", "value": {"stringValue": "api"}},
+		{"key": "host.name", "value": {"stringValue": "web-1"}}
+	]},
+	"scopeLogs": [{
+		"scope": {"name": "http", "version": "1.0"},
+		"logRecords": [
+			{
+				"timeUnixNano": "1659530060000000000",
+				"observedTimeUnixNano": "1659530060000000001",
+				"severityNumber": 9,
+				"severityText": "Information",
+				"body": {"stringValue": "request"},
+				"attributes": [
+					{"key": "http.method", "value": {"stringValue": "GET"}},
+					{"key": "http.status_code", "value": {"intValue": "200"}},
+					{"key": "retry", "value": {"boolValue": false}},
+					{"key": "tags", "value": {"arrayValue": {"values": [{"stringValue": "a"}, {"doubleValue": 1.5}]}}},
+					{"key": "user", "value": {"kvlistValue": {"values": [{"key": "id", "value": {"intValue": "7"}}]}}}
+				],
+				"traceId": "5b8efff798038103d269b633813fc60c",
+				"spanId": "eee19b7ec3c1b174",
+				"flags": 1
+			},
+			{
+				"timeUnixNano": "1659530061000000000",
+				"severityText": "ERROR",
+				"body": {"kvlistValue": {"values": [{"key": "reason", "value": {"stringValue": "timeout"}}]}}
+			}
+		]
+	}]
+}]}`
+
+func decode(t *testing.T, s string) j {
+	t.Helper()
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	var m j
+	require.NoError(t, dec.Decode(&m))
+	return m
+}
+
+func TestIsOTel(t *testing.T) {
+	require.True(t, schema.IsOTel(j{"resourceLogs": []any{}}))
+	require.True(t, schema.IsOTel(j{"severityText": "INFO", "body": "hi"}))
+	require.True(t, schema.IsOTel(j{"severityNumber": 9, "timeUnixNano": "1"}))
+	require.False(t, schema.IsOTel(j{"severityText": "INFO"}))
+	require.False(t, schema.IsOTel(j{"level": "info", "body": "hi"}))
+}
+
+func TestFromOTel(t *testing.T) {
+	records := schema.FromOTel(decode(t, export))
+	require.Equal(t, []j{
+		{
+			"time":             "2022-08-03T12:34:20Z",
+			"level":            "info",
+			"message":          "request",
+			"service":          "api",
+			"resource":         j{"host.name": "web-1"},
+			"logger":           "http",
+			"trace_id":         "5b8efff798038103d269b633813fc60c",
+			"span_id":          "eee19b7ec3c1b174",
+			"http.method":      "GET",
+			"http.status_code": json.Number("200"),
+			"retry":            false,
+			"tags":             []any{"a", json.Number("1.5")},
+			"user":             j{"id": json.Number("7")},
+		},
+		{
+			"time":     "2022-08-03T12:34:21Z",
+			"level":    "error",
+			"body":     j{"reason": "timeout"},
+			"service":  "api",
+			"resource": j{"host.name": "web-1"},
+			"logger":   "http",
+		},
+	}, records)
+}
+
+func TestFromOTel_Record(t *testing.T) {
+	records := schema.FromOTel(decode(t, `{
+		"timeUnixNano": 1659530060000000000,
+		"severityNumber": 17,
+		"body": "failed",
+		"attributes": {"code": 3},
+		"resource": {"service.name": "worker"},
+		"thread": "main"
+	}`))
+	require.Equal(t, []j{{
+		"time":    "2022-08-03T12:34:20Z",
+		"level":   "error",
+		"message": "failed",
+		"code":    json.Number("3"),
+		"service": "worker",
+		"thread":  "main",
+	}}, records)
+
+	other := j{"level": "info"}
+	require.Equal(t, []j{other}, schema.FromOTel(other))
+}
@@ -0,0 +1,174 @@
+// Package schema maps records of well known log schemas onto the keys hz pins,
+// e.g. the log.level of Elastic Common Schema onto level.
+//
+// ECS moves log.level to level, error.message to error, service.name to
+// service, log.logger to logger and log.origin.file to caller. OTel turns
+// OpenTelemetry log records, as the OTLP JSON encoding or a single record,
+// into records of their attributes with the time, level, message, service
+// and logger set.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dcilke/hz/pkg/formatter"
+	"github.com/dcilke/hz/pkg/pipeline"
+)
+
+const (
+	// Auto maps each record of a schema it recognizes.
+	Auto = "auto"
+	// ECS maps Elastic Common Schema records.
+	ECS = "ecs"
+	// OTel maps OpenTelemetry log records.
+	OTel = "otel"
+
+	KeyService = "service"
+	KeyLogger  = "logger"
+	KeyTraceID = "trace_id"
+	KeySpanID  = "span_id"
+)
+
+// Names are the schemas New accepts.
+var Names = []string{Auto, ECS, OTel}
+
+// New returns the Expander mapping records of the named schema.
+func New(name string) (pipeline.Expander, error) {
+	switch name {
+	case Auto:
+		return pipeline.ExpanderFunc(expandAuto), nil
+	case ECS:
+		return pipeline.ExpanderFunc(expandECS), nil
+	case OTel:
+		return pipeline.ExpanderFunc(expandOTel), nil
+	}
+	return nil, fmt.Errorf("unknown schema %q, expected one of %s", name, strings.Join(Names, ", "))
+}
+
+func expandAuto(r pipeline.Record) ([]pipeline.Record, error) {
+	m, ok := r.Map()
+	switch {
+	case !ok:
+		return []pipeline.Record{r}, nil
+	case IsOTel(m):
+		return expandOTel(r)
+	case IsECS(m):
+		return expandECS(r)
+	}
+	return []pipeline.Record{r}, nil
+}
+
+func expandECS(r pipeline.Record) ([]pipeline.Record, error) {
+	if m, ok := r.Map(); ok {
+		FromECS(m)
+	}
+	return []pipeline.Record{r}, nil
+}
+
+func expandOTel(r pipeline.Record) ([]pipeline.Record, error) {
+	m, ok := r.Map()
+	if !ok {
+		return []pipeline.Record{r}, nil
+	}
+	values := FromOTel(m)
+	records := make([]pipeline.Record, len(values))
+	for i, v := range values {
+		records[i] = pipeline.Record{Value: v}
+	}
+	return records, nil
+}
+
+// has reports whether m holds the dotted path, as a dotted key or nested in
+// objects.
+func has(m map[string]any, path string) bool {
+	if _, ok := m[path]; ok {
+		return true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		if sub, ok := m[path[:i]].(map[string]any); ok && has(sub, path[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// only reports whether the dotted path is all m holds.
+func only(m map[string]any, path string) bool {
+	if len(m) != 1 {
+		return false
+	}
+	if _, ok := m[path]; ok {
+		return true
+	}
+	for k, v := range m {
+		sub, ok := v.(map[string]any)
+		return ok && strings.HasPrefix(path, k+".") && only(sub, path[len(k)+1:])
+	}
+	return false
+}
+
+// take removes the value at the dotted path from m, along with the objects
+// left empty.
+func take(m map[string]any, path string) (any, bool) {
+	if v, ok := m[path]; ok {
+		delete(m, path)
+		return v, true
+	}
+	for i := 0; i < len(path); i++ {
+		if path[i] != '.' {
+			continue
+		}
+		sub, ok := m[path[:i]].(map[string]any)
+		if !ok {
+			continue
+		}
+		if v, ok := take(sub, path[i+1:]); ok {
+			if len(sub) == 0 {
+				delete(m, path[:i])
+			}
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// move moves the value at the dotted path in m to key, unless key holds
+// something else. The value of service.name moves to service only if the
+// service object holds nothing else.
+func move(m map[string]any, path string, key string) bool {
+	if !has(m, path) {
+		return false
+	}
+	if cur, ok := m[key]; ok {
+		obj, ok := cur.(map[string]any)
+		if !ok || !strings.HasPrefix(path, key+".") || !only(obj, path[len(key)+1:]) {
+			return false
+		}
+	}
+	v, _ := take(m, path)
+	m[key] = v
+	return true
+}
+
+// severity returns the level of an OpenTelemetry severity number.
+func severity(n int64) string {
+	switch {
+	case n <= 0:
+		return ""
+	case n <= 4:
+		return formatter.LevelTraceStr
+	case n <= 8:
+		return formatter.LevelDebugStr
+	case n <= 12:
+		return formatter.LevelInfoStr
+	case n <= 16:
+		return formatter.LevelWarnStr
+	case n <= 20:
+		return formatter.LevelErrorStr
+	}
+	return formatter.LevelFatalStr
+}
@@ -0,0 +1,54 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	_, err := schema.New("gelf")
+	require.EqualError(t, err, `unknown schema "gelf", expected one of auto, ecs, otel`)
+}
+
+func TestAuto(t *testing.T) {
+	e, err := schema.New(schema.Auto)
+	require.NoError(t, err)
+
+	testcases := map[string]struct {
+		in   pipeline.Record
+		want []pipeline.Record
+	}{
+		"ecs": {
+			pipeline.Record{Value: j{"ecs.version": "1.6.0", "log.level": "warn", "message": "slow"}},
+			[]pipeline.Record{{Value: j{"level": "warn", "message": "slow"}}},
+		},
+		"otel": {
+			pipeline.Record{Value: j{"resourceLogs": []any{j{"scopeLogs": []any{j{"logRecords": []any{
+				j{"severityText": "WARN", "body": j{"stringValue": "slow"}},
+				j{"severityText": "INFO", "body": j{"stringValue": "done"}},
+			}}}}}}},
+			[]pipeline.Record{
+				{Value: j{"level": "warn", "message": "slow"}},
+				{Value: j{"level": "info", "message": "done"}},
+			},
+		},
+		"other": {
+			pipeline.Record{Value: j{"log": j{"level": "warn"}, "message": "slow"}},
+			[]pipeline.Record{{Value: j{"log": j{"level": "warn"}, "message": "slow"}}},
+		},
+		"text": {
+			pipeline.Record{Text: []byte("text\n")},
+			[]pipeline.Record{{Text: []byte("text\n")}},
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			records, err := e.Expand(tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, records)
+		})
+	}
+}
@@ -0,0 +1,365 @@
+// Package script runs records through a transform function written in
+// Starlark, a sandboxed dialect of Python without access to the file system,
+// network or clock.
+//
+// The script defines transform(record), called with each record as a dict.
+// Its return value decides what comes out:
+//
+//	None           the record, with any changes made to it
+//	a dict         the dict instead of the record
+//	a list of dicts each dict in turn, so an empty list drops the record
+//	False          nothing, the record is dropped
+//
+// The json and math modules are predeclared.
+package script
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	starjson "go.starlark.net/lib/json"
+	starmath "go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	// FuncName is the name of the function called with each record.
+	FuncName = "transform"
+	// DefaultTimeout is the default time budget of each call.
+	DefaultTimeout = 100 * time.Millisecond
+	// DefaultMaxSteps is the default step budget of each call, a hard bound
+	// alongside the time budget.
+	DefaultMaxSteps = 10000000
+)
+
+var (
+	// ErrTimeout is returned when a call runs out of its time budget.
+	ErrTimeout = errors.New("time budget exceeded")
+	// ErrMaxSteps is returned when a call runs out of its step budget.
+	ErrMaxSteps = errors.New("step budget exceeded")
+)
+
+// Ensure we are adhering to the pipeline.Expander interface.
+var _ pipeline.Expander = (*Script)(nil)
+
+type Option func(*Script)
+
+// WithTimeout sets the time budget of each call, 0 is unlimited.
+func WithTimeout(d time.Duration) Option {
+	return func(s *Script) {
+		s.timeout = d
+	}
+}
+
+// WithMaxSteps sets the number of Starlark computation steps each call, and
+// loading the script, may take, 0 is unlimited.
+func WithMaxSteps(n uint64) Option {
+	return func(s *Script) {
+		s.maxSteps = n
+	}
+}
+
+// WithPrint sets where the script's print output goes, stderr by default.
+func WithPrint(w io.Writer) Option {
+	return func(s *Script) {
+		s.print = w
+	}
+}
+
+// Script is a loaded script.
+type Script struct {
+	name     string
+	fn       starlark.Callable
+	timeout  time.Duration
+	maxSteps uint64
+	print    io.Writer
+}
+
+// Load reads and runs the script in file, which must define transform.
+func Load(file string, opts ...Option) (*Script, error) {
+	src, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read script: %w", err)
+	}
+	return Parse(file, src, opts...)
+}
+
+// Parse runs the script src, named name in errors, which must define transform.
+func Parse(name string, src []byte, opts ...Option) (*Script, error) {
+	s := &Script{
+		name:     name,
+		timeout:  DefaultTimeout,
+		maxSteps: DefaultMaxSteps,
+		print:    os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	predeclared := starlark.StringDict{
+		"json": starjson.Module,
+		"math": starmath.Module,
+	}
+	fileOpts := &syntax.FileOptions{
+		Set:             true,
+		While:           true,
+		TopLevelControl: true,
+		GlobalReassign:  true,
+		Recursion:       true,
+	}
+	globals, err := starlark.ExecFileOptions(fileOpts, s.thread(), name, src, predeclared)
+	if err != nil {
+		return nil, err
+	}
+	// records can't leave state behind in the globals
+	globals.Freeze()
+	fn, ok := globals[FuncName].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s is not defined as a function", name, FuncName)
+	}
+	s.fn = fn
+	return s, nil
+}
+
+func (s *Script) thread() *starlark.Thread {
+	thread := &starlark.Thread{
+		Name: s.name,
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(s.print, msg)
+		},
+		// load is not allowed, scripts are a single file
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load(%q) is not supported", module)
+		},
+	}
+	if s.maxSteps > 0 {
+		thread.SetMaxExecutionSteps(s.maxSteps)
+		thread.OnMaxSteps = func(thread *starlark.Thread) {
+			thread.Cancel(ErrMaxSteps.Error())
+		}
+	}
+	return thread
+}
+
+// Expand calls transform with each object, other records pass unchanged. If
+// transform fails the record is returned along with the error.
+func (s *Script) Expand(r pipeline.Record) ([]pipeline.Record, error) {
+	m, ok := r.Map()
+	if !ok {
+		return []pipeline.Record{r}, nil
+	}
+	values, err := s.Transform(m)
+	if err != nil {
+		return []pipeline.Record{r}, err
+	}
+	records := make([]pipeline.Record, len(values))
+	for i, v := range values {
+		records[i] = pipeline.Record{Value: v}
+	}
+	return records, nil
+}
+
+// Transform calls transform with m, returning the records it results in.
+func (s *Script) Transform(m map[string]any) ([]map[string]any, error) {
+	record, err := toStarlark(m)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.name, err)
+	}
+
+	// the budgets cover the call alone, not converting the record
+	thread := s.thread()
+	var timedOut atomic.Bool
+	if s.timeout > 0 {
+		timer := time.AfterFunc(s.timeout, func() {
+			timedOut.Store(true)
+			thread.Cancel(ErrTimeout.Error())
+		})
+		defer timer.Stop()
+	}
+	v, err := starlark.Call(thread, s.fn, starlark.Tuple{record}, nil)
+	if err != nil {
+		switch {
+		case timedOut.Load():
+			return nil, fmt.Errorf("%s: %w after %s", s.name, ErrTimeout, s.timeout)
+		case s.maxSteps > 0 && thread.ExecutionSteps() >= s.maxSteps:
+			return nil, fmt.Errorf("%s: %w after %d steps", s.name, ErrMaxSteps, s.maxSteps)
+		}
+		return nil, fmt.Errorf("%s: %w", s.name, err)
+	}
+
+	switch vv := v.(type) {
+	case starlark.NoneType:
+		return one(record)
+	case starlark.Bool:
+		if !vv {
+			return nil, nil
+		}
+	case *starlark.Dict:
+		return one(vv)
+	case *starlark.List:
+		out := make([]map[string]any, 0, vv.Len())
+		for i := 0; i < vv.Len(); i++ {
+			d, ok := vv.Index(i).(*starlark.Dict)
+			if !ok {
+				return nil, fmt.Errorf("%s: %s returned a list holding %s, not a dict", s.name, FuncName, vv.Index(i).Type())
+			}
+			m, err := fromDict(d)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", s.name, err)
+			}
+			out = append(out, m)
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("%s: %s returned %s, expected None, False, a dict or a list of dicts", s.name, FuncName, v.Type())
+}
+
+func one(v starlark.Value) ([]map[string]any, error) {
+	m, err := fromDict(v.(*starlark.Dict))
+	if err != nil {
+		return nil, err
+	}
+	return []map[string]any{m}, nil
+}
+
+// toStarlark converts a decoded JSON value to a Starlark value.
+func toStarlark(v any) (starlark.Value, error) {
+	switch vv := v.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(vv), nil
+	case string:
+		return starlark.String(vv), nil
+	case json.Number:
+		if i, err := vv.Int64(); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		if i, ok := new(big.Int).SetString(vv.String(), 10); ok {
+			return starlark.MakeBigInt(i), nil
+		}
+		f, err := vv.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return starlark.Float(f), nil
+	case float64:
+		return starlark.Float(vv), nil
+	case int:
+		return starlark.MakeInt(vv), nil
+	case int64:
+		return starlark.MakeInt64(vv), nil
+	case map[string]any:
+		d := starlark.NewDict(len(vv))
+		for k, e := range vv {
+			sv, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			if err := d.SetKey(starlark.String(k), sv); err != nil {
+				return nil, err
+			}
+		}
+		return d, nil
+	case []any:
+		l := make([]starlark.Value, 0, len(vv))
+		for _, e := range vv {
+			sv, err := toStarlark(e)
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, sv)
+		}
+		return starlark.NewList(l), nil
+	}
+	return starlark.String(fmt.Sprint(v)), nil
+}
+
+func fromDict(d *starlark.Dict) (map[string]any, error) {
+	return converter{}.dict(d)
+}
+
+// converter converts Starlark values to Go, holding the dicts and lists being
+// converted so a value containing itself is an error rather than endless.
+type converter map[starlark.Value]bool
+
+func (c converter) enter(v starlark.Value) error {
+	if c[v] {
+		return fmt.Errorf("%s returned a %s containing itself", FuncName, v.Type())
+	}
+	c[v] = true
+	return nil
+}
+
+func (c converter) dict(d *starlark.Dict) (map[string]any, error) {
+	if err := c.enter(d); err != nil {
+		return nil, err
+	}
+	defer delete(c, d)
+	m := make(map[string]any, d.Len())
+	for _, item := range d.Items() {
+		k, ok := starlark.AsString(item[0])
+		if !ok {
+			k = item[0].String()
+		}
+		v, err := c.value(item[1])
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+// value converts a Starlark value to the value decoding it as JSON would
+// give. Numbers are kept as json.Number.
+func (c converter) value(v starlark.Value) (any, error) {
+	switch vv := v.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(vv), nil
+	case starlark.String:
+		return string(vv), nil
+	case starlark.Bytes:
+		return string(vv), nil
+	case starlark.Int:
+		return json.Number(vv.String()), nil
+	case starlark.Float:
+		f := float64(vv)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return vv.String(), nil
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), nil
+	case *starlark.Dict:
+		return c.dict(vv)
+	case starlark.Indexable:
+		// lists and tuples, only lists can contain themselves
+		if l, ok := vv.(*starlark.List); ok {
+			if err := c.enter(l); err != nil {
+				return nil, err
+			}
+			defer delete(c, l)
+		}
+		l := make([]any, 0, vv.Len())
+		for i := 0; i < vv.Len(); i++ {
+			e, err := c.value(vv.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, e)
+		}
+		return l, nil
+	}
+	return v.String(), nil
+}
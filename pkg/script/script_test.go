@@ -0,0 +1,197 @@
+package script_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/pkg/pipeline"
+	"github.com/dcilke/hz/pkg/script"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+
+func parse(t *testing.T, src string, opts ...script.Option) *script.Script {
+	t.Helper()
+	s, err := script.Parse("test.star", []byte(src), opts...)
+	require.NoError(t, err)
+	return s
+}
+
+func TestTransform(t *testing.T) {
+	testcases := map[string]struct {
+		src  string
+		in   j
+		want []map[string]any
+	}{
+		"modify": {
+			`def transform(r):
+    r["elapsed_ms"] = r["elapsed_us"] // 1000
+    r.pop("elapsed_us")`,
+			j{"elapsed_us": json.Number("1532000")},
+			[]map[string]any{{"elapsed_ms": json.Number("1532")}},
+		},
+		"compute": {
+			`def transform(r):
+    r["ratio"] = r["hits"] / r["total"]`,
+			j{"hits": json.Number("1"), "total": json.Number("4")},
+			[]map[string]any{{"hits": json.Number("1"), "total": json.Number("4"), "ratio": json.Number("0.25")}},
+		},
+		"rewrite": {
+			`def transform(r):
+    r["message"] = r["message"].replace("usr", "user").upper()`,
+			j{"message": "usr created"},
+			[]map[string]any{{"message": "USER CREATED"}},
+		},
+		"replace": {
+			`def transform(r):
+    return {"msg": r["message"], "user": r["ctx"]["user"]["id"]}`,
+			j{"message": "login", "ctx": j{"user": j{"id": json.Number("42")}}},
+			[]map[string]any{{"msg": "login", "user": json.Number("42")}},
+		},
+		"drop": {
+			`def transform(r):
+    if r.get("level") == "debug" and "health" in r.get("path", ""):
+        return False`,
+			j{"level": "debug", "path": "/healthz"},
+			nil,
+		},
+		"keep": {
+			`def transform(r):
+    if r.get("level") == "debug" and "health" in r.get("path", ""):
+        return False`,
+			j{"level": "debug", "path": "/api", "tags": []any{"a", nil, true}},
+			[]map[string]any{{"level": "debug", "path": "/api", "tags": []any{"a", nil, true}}},
+		},
+		"fan-out": {
+			`def transform(r):
+    return [{"item": i} for i in r["items"]]`,
+			j{"items": []any{json.Number("1"), json.Number("2")}},
+			[]map[string]any{{"item": json.Number("1")}, {"item": json.Number("2")}},
+		},
+		"empty": {
+			`def transform(r):
+    return []`,
+			j{"a": "b"},
+			[]map[string]any{},
+		},
+		"json": {
+			`def transform(r):
+    r["payload"] = json.decode(r["payload"])`,
+			j{"payload": `{"id":7}`},
+			[]map[string]any{{"payload": j{"id": json.Number("7")}}},
+		},
+		"shared": {
+			`def transform(r):
+    x = {"a": 1}
+    return {"p": x, "q": [x, x]}`,
+			j{},
+			[]map[string]any{{"p": j{"a": json.Number("1")}, "q": []any{j{"a": json.Number("1")}, j{"a": json.Number("1")}}}},
+		},
+		"big": {
+			`def transform(r):
+    r["n"] = r["n"] + 1`,
+			j{"n": json.Number("18446744073709551616")},
+			[]map[string]any{{"n": json.Number("18446744073709551617")}},
+		},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parse(t, tc.src).Transform(tc.in)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestTransform_Errors(t *testing.T) {
+	testcases := map[string]struct {
+		src string
+		err string
+	}{
+		"runtime": {"def transform(r):\n    return r[\"missing\"]", `key "missing" not in dict`},
+		"result":  {"def transform(r):\n    return 5", "transform returned int, expected None, False, a dict or a list of dicts"},
+		"list":    {"def transform(r):\n    return [1]", "transform returned a list holding int, not a dict"},
+		"global":  {"seen = []\ndef transform(r):\n    seen.append(r)", "frozen list"},
+		"cycle":   {"def transform(r):\n    r[\"self\"] = r", "transform returned a dict containing itself"},
+		"nested":  {"def transform(r):\n    l = []\n    l.append({\"l\": l})\n    r[\"l\"] = l", "transform returned a list containing itself"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse(t, tc.src).Transform(j{})
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestTransform_Timeout(t *testing.T) {
+	s := parse(t, "def transform(r):\n    while True:\n        pass", script.WithTimeout(20*time.Millisecond))
+	start := time.Now()
+	_, err := s.Transform(j{})
+	require.True(t, errors.Is(err, script.ErrTimeout), err)
+	require.Less(t, time.Since(start), time.Second)
+
+	// the budget applies to each record
+	_, err = s.Transform(j{})
+	require.True(t, errors.Is(err, script.ErrTimeout), err)
+}
+
+func TestTransform_MaxSteps(t *testing.T) {
+	s := parse(t, "def transform(r):\n    while True:\n        pass", script.WithTimeout(0), script.WithMaxSteps(1000))
+	_, err := s.Transform(j{})
+	require.True(t, errors.Is(err, script.ErrMaxSteps), err)
+
+	// the budget applies to each record
+	_, err = s.Transform(j{})
+	require.True(t, errors.Is(err, script.ErrMaxSteps), err)
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := script.Parse("test.star", []byte("x = 1"))
+	require.EqualError(t, err, "test.star: transform is not defined as a function")
+
+	_, err = script.Parse("test.star", []byte("def transform(r)\n"))
+	require.ErrorContains(t, err, "test.star:2:1: got newline, want ':'")
+
+	_, err = script.Parse("test.star", []byte(`load("os.star", "os")`))
+	require.ErrorContains(t, err, `load("os.star") is not supported`)
+
+	_, err = script.Load(filepath.Join(t.TempDir(), "missing.star"))
+	require.ErrorContains(t, err, "unable to read script")
+}
+
+func TestLoad(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "tag.star")
+	require.NoError(t, os.WriteFile(file, []byte("def transform(r):\n    print('tagging')\n    r['team'] = 'api'"), 0o600))
+	out := new(bytes.Buffer)
+	s, err := script.Load(file, script.WithPrint(out))
+	require.NoError(t, err)
+	got, err := s.Transform(j{})
+	require.NoError(t, err)
+	require.Equal(t, []map[string]any{{"team": "api"}}, got)
+	require.Equal(t, "tagging\n", out.String())
+}
+
+func TestExpand(t *testing.T) {
+	s := parse(t, "def transform(r):\n    if r['n'] > 1:\n        fail('too big')\n    r['n'] += 1")
+
+	got, err := s.Expand(pipeline.Record{Value: j{"n": json.Number("1")}})
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{{Value: j{"n": json.Number("2")}}}, got)
+
+	// on failure the record passes unchanged
+	in := pipeline.Record{Value: j{"n": json.Number("5")}}
+	got, err = s.Expand(in)
+	require.ErrorContains(t, err, "too big")
+	require.Equal(t, []pipeline.Record{in}, got)
+
+	text := pipeline.Record{Text: []byte("text\n")}
+	got, err = s.Expand(text)
+	require.NoError(t, err)
+	require.Equal(t, []pipeline.Record{text}, got)
+}
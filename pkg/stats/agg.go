@@ -0,0 +1,227 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+const (
+	// DefaultBins is the number of histogram bins.
+	DefaultBins = 10
+
+	barWidth = 40
+	bar      = "█"
+)
+
+// Agg aggregates the values of a numeric field, optionally grouped by the
+// value of another field. It is safe for concurrent use.
+type Agg struct {
+	mu       sync.Mutex
+	field    string
+	by       string
+	levels   []string
+	bins     int
+	sketches map[string]*Sketch
+}
+
+// AggOption configures Agg.
+type AggOption func(*Agg)
+
+// WithGroupBy aggregates separately for each value of the field by.
+func WithGroupBy(by string) AggOption {
+	return func(a *Agg) {
+		a.by = by
+	}
+}
+
+// WithAggLevels only aggregates records at one of levels.
+func WithAggLevels(levels []string) AggOption {
+	return func(a *Agg) {
+		a.levels = levels
+	}
+}
+
+// WithBins sets the number of histogram bins, 0 disables the histogram.
+func WithBins(n int) AggOption {
+	return func(a *Agg) {
+		a.bins = n
+	}
+}
+
+// NewAgg aggregates the numeric field, which may be a dotted path.
+func NewAgg(field string, opts ...AggOption) *Agg {
+	a := &Agg{
+		field:    field,
+		bins:     DefaultBins,
+		sketches: make(map[string]*Sketch),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Record adds a decoded JSON value, arrays add each of their elements. Records
+// where the field is missing or not a number are skipped.
+func (a *Agg) Record(v any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.record(v)
+}
+
+func (a *Agg) record(v any) {
+	switch vv := v.(type) {
+	case map[string]any:
+		if !formatter.MatchLevels(vv, a.levels) {
+			return
+		}
+		f, ok := formatter.Lookup(vv, a.field)
+		if !ok {
+			return
+		}
+		n, ok := Number(f)
+		if !ok {
+			return
+		}
+		group := ""
+		if a.by != "" {
+			group = NoValue
+			if g, ok := formatter.Lookup(vv, a.by); ok && g != nil {
+				group = String(g)
+			}
+		}
+		if _, ok := a.sketches[group]; !ok && len(a.sketches) >= maxDistinct {
+			group = Other
+		}
+		s, ok := a.sketches[group]
+		if !ok {
+			s = NewSketch()
+			a.sketches[group] = s
+		}
+		s.Add(n)
+	case []any:
+		for _, vvv := range vv {
+			a.record(vvv)
+		}
+	}
+}
+
+// Sketch returns the sketch of the group, which is the value of the group by
+// field or "" when not grouping.
+func (a *Agg) Sketch(group string) *Sketch {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.sketches[group]
+}
+
+// Groups returns the groups seen, largest first.
+func (a *Agg) Groups() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	groups := make([]string, 0, len(a.sketches))
+	for g := range a.sketches {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		ci, cj := a.sketches[groups[i]].Count(), a.sketches[groups[j]].Count()
+		if ci != cj {
+			return ci > cj
+		}
+		return groups[i] < groups[j]
+	})
+	return groups
+}
+
+// Write writes a table of the statistics followed by the histograms to w.
+func (a *Agg) Write(w io.Writer) error {
+	groups := a.Groups()
+	if len(groups) == 0 {
+		_, err := fmt.Fprintf(w, "no numeric values of %s\n", a.field)
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	header := "count\tmin\tmean\tp50\tp90\tp99\tmax"
+	if a.by != "" {
+		header = a.by + "\t" + header
+	}
+	fmt.Fprintln(tw, header)
+	for _, g := range groups {
+		s := a.sketches[g]
+		if a.by != "" {
+			fmt.Fprintf(tw, "%s\t", g)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", s.Count(),
+			number(s.Min()), number(s.Mean()),
+			number(s.Quantile(0.5)), number(s.Quantile(0.9)), number(s.Quantile(0.99)),
+			number(s.Max()),
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if a.bins <= 0 {
+		return nil
+	}
+	for _, g := range groups {
+		fmt.Fprintln(w)
+		if a.by != "" {
+			fmt.Fprintln(w, g)
+		}
+		if err := histogram(w, a.sketches[g].Histogram(a.bins)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func histogram(w io.Writer, bins []Bin) error {
+	most := 0
+	for _, b := range bins {
+		if b.Count > most {
+			most = b.Count
+		}
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 1, ' ', tabwriter.AlignRight)
+	for _, b := range bins {
+		n := 0
+		if most > 0 {
+			n = int(math.Round(float64(b.Count) * barWidth / float64(most)))
+		}
+		fmt.Fprintf(tw, "%s\t-\t%s\t %s %d\n", number(b.Low), number(b.High), strings.Repeat(bar, n), b.Count)
+	}
+	return tw.Flush()
+}
+
+// Number returns v as a number, numeric strings included.
+func Number(v any) (float64, bool) {
+	switch vv := v.(type) {
+	case json.Number:
+		f, err := vv.Float64()
+		return f, err == nil
+	case float64:
+		return vv, true
+	case string:
+		f, err := strconv.ParseFloat(vv, 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// number formats f with at most three decimal places.
+func number(f float64) string {
+	return strconv.FormatFloat(math.Round(f*1000)/1000, 'f', -1, 64)
+}
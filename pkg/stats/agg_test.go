@@ -0,0 +1,94 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+var aggRecords = []any{
+	j{"level": "info", "path": "/api/users", "duration_ms": json.Number("12")},
+	j{"level": "info", "path": "/api/users", "duration_ms": json.Number("15")},
+	j{"level": "info", "path": "/api/users", "duration_ms": json.Number("9")},
+	j{"level": "warn", "path": "/api/orders", "duration_ms": json.Number("1532")},
+	a{
+		j{"level": "info", "path": "/api/orders", "duration_ms": "48.5"},
+		j{"level": "info", "duration_ms": json.Number("3")},
+	},
+	j{"level": "info", "path": "/api/users", "duration_ms": "slow"},
+	j{"level": "info", "path": "/api/users"},
+}
+
+func TestAgg(t *testing.T) {
+	agg := stats.NewAgg("duration_ms")
+	for _, r := range aggRecords {
+		agg.Record(r)
+	}
+	s := agg.Sketch("")
+	require.Equal(t, 6, s.Count())
+	require.Equal(t, 3.0, s.Min())
+	require.Equal(t, 1532.0, s.Max())
+
+	levels := stats.NewAgg("duration_ms", stats.WithAggLevels([]string{"warn"}))
+	for _, r := range aggRecords {
+		levels.Record(r)
+	}
+	require.Equal(t, []string{""}, levels.Groups())
+	require.Equal(t, 1, levels.Sketch("").Count())
+}
+
+func TestAgg_Other(t *testing.T) {
+	agg := stats.NewAgg("n", stats.WithGroupBy("id"))
+	for i := 0; i < 10002; i++ {
+		agg.Record(j{"id": strconv.Itoa(i), "n": json.Number("1")})
+	}
+	require.Len(t, agg.Groups(), 10001)
+	require.Equal(t, 2, agg.Sketch(stats.Other).Count())
+	require.Equal(t, stats.Other, agg.Groups()[0])
+}
+
+func TestAgg_Write(t *testing.T) {
+	testcases := map[string][]stats.AggOption{
+		"all":     nil,
+		"by":      {stats.WithGroupBy("path"), stats.WithBins(0)},
+		"by-bins": {stats.WithGroupBy("path"), stats.WithBins(3)},
+		"none":    {stats.WithAggLevels([]string{"error"})},
+	}
+	for name, opts := range testcases {
+		t.Run(name, func(t *testing.T) {
+			agg := stats.NewAgg("duration_ms", opts...)
+			for _, r := range aggRecords {
+				agg.Record(r)
+			}
+			buf := new(bytes.Buffer)
+			require.NoError(t, agg.Write(buf))
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestNumber(t *testing.T) {
+	testcases := map[string]struct {
+		value  any
+		expect float64
+		ok     bool
+	}{
+		"number": {json.Number("1.5"), 1.5, true},
+		"float":  {2.0, 2, true},
+		"string": {"42", 42, true},
+		"word":   {"slow", 0, false},
+		"bool":   {true, 0, false},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			n, ok := stats.Number(tc.value)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expect, n)
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcilke/gu"
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+// errorLevels are the levels grouped by Groups.
+var errorLevels = []string{
+	formatter.LevelErrorStr,
+	formatter.LevelFatalStr,
+	formatter.LevelPanicStr,
+}
+
+// normalizers replace the variable parts of a message, in order.
+var normalizers = []struct {
+	re   *regexp.Regexp
+	repl func(string) string
+}{
+	{regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`), replace(`"*"`)},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), replace("<uuid>")},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]{6,}\b`), hex},
+	// numbers start a word, so names such as http2 or ipv4 are kept
+	{regexp.MustCompile(`\b\d+(?:\.\d+)?`), replace("<n>")},
+}
+
+func replace(repl string) func(string) string {
+	return func(string) string {
+		return repl
+	}
+}
+
+// hex replaces 0x prefixed numbers and runs of hex digits mixing digits and
+// letters, so words such as "deadline" or "facade" are kept.
+func hex(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return "<hex>"
+	}
+	if strings.IndexAny(s, "0123456789") >= 0 && strings.IndexAny(s, "abcdefABCDEF") >= 0 {
+		return "<hex>"
+	}
+	return s
+}
+
+// Group is a set of records sharing a fingerprint.
+type Group struct {
+	Fingerprint string
+	Count       int
+	First       time.Time
+	Last        time.Time
+	Example     map[string]any
+
+	seq int
+}
+
+// Groups groups records at error level and above by their fingerprint. It is
+// safe for concurrent use.
+type Groups struct {
+	mu     sync.Mutex
+	groups map[string]*Group
+}
+
+func NewGroups() *Groups {
+	return &Groups{
+		groups: make(map[string]*Group),
+	}
+}
+
+// Record adds a decoded JSON value, arrays add each of their elements.
+func (g *Groups) Record(a any) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.record(a)
+}
+
+func (g *Groups) record(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		if !gu.Includes(errorLevels, Level(v)) {
+			return
+		}
+		fp := Fingerprint(v)
+		grp, ok := g.groups[fp]
+		if !ok {
+			if len(g.groups) >= maxDistinct {
+				return
+			}
+			grp = &Group{Fingerprint: fp, Example: v, seq: len(g.groups)}
+			g.groups[fp] = grp
+		}
+		grp.Count++
+		if t, ok := Time(v); ok {
+			if grp.First.IsZero() || t.Before(grp.First) {
+				grp.First = t
+			}
+			if t.After(grp.Last) {
+				grp.Last = t
+			}
+		}
+	case []any:
+		for _, vv := range v {
+			g.record(vv)
+		}
+	}
+}
+
+// Groups returns the groups, most frequent first and then in the order they
+// were first seen.
+func (g *Groups) Groups() []Group {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	groups := make([]Group, 0, len(g.groups))
+	for _, grp := range g.groups {
+		groups = append(groups, *grp)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].seq < groups[j].seq
+	})
+	return groups
+}
+
+// Fingerprint identifies the kind of error a record describes: its message,
+// with numbers, UUIDs, hex and quoted strings normalized, and its caller.
+func Fingerprint(m map[string]any) string {
+	msg, _ := value(m, formatter.KeyMessage, formatter.KeyMsg)
+	if msg == "" {
+		msg, _ = value(m, formatter.KeyError, formatter.KeyErr)
+	}
+	msg = Normalize(msg)
+	if caller, ok := value(m, formatter.KeyCaller); ok {
+		return fmt.Sprintf("%s @ %s", msg, caller)
+	}
+	return msg
+}
+
+// Normalize replaces the numbers, UUIDs, hex and quoted strings in s.
+func Normalize(s string) string {
+	for _, n := range normalizers {
+		s = n.re.ReplaceAllStringFunc(s, n.repl)
+	}
+	return s
+}
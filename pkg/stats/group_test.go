@@ -0,0 +1,76 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	testcases := map[string]struct {
+		msg    string
+		expect string
+	}{
+		"plain":   {"connection refused", "connection refused"},
+		"number":  {"retry 3 of 10 after 1.5s", "retry <n> of <n> after <n>s"},
+		"uuid":    {"user 3f2b8c1e-9d4a-4e6b-8f0a-1c2d3e4f5a6b not found", "user <uuid> not found"},
+		"hex":     {"bad address 0xc000123abc", "bad address <hex>"},
+		"sha":     {"missing object 9fceb02d0ae598e95dc970b74767f19372d61af8", "missing object <hex>"},
+		"word":    {"facade deadline exceeded", "facade deadline exceeded"},
+		"short":   {"step a1 failed", "step a1 failed"},
+		"names":   {"http2 stream 7 reset by s3 over ipv4", "http2 stream <n> reset by s3 over ipv4"},
+		"units":   {"took 12ms, port:8080", "took <n>ms, port:<n>"},
+		"quoted":  {`open "/tmp/a.txt": no such file`, `open "*": no such file`},
+		"single":  {`unknown key 'foo'`, `unknown key "*"`},
+		"escaped": {`bad "a \"b\" c" value`, `bad "*" value`},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, stats.Normalize(tc.msg))
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	testcases := map[string]struct {
+		msg    map[string]any
+		expect string
+	}{
+		"message": {j{"message": "user 42 not found"}, "user <n> not found"},
+		"msg":     {j{"msg": "user 42 not found"}, "user <n> not found"},
+		"caller":  {j{"message": "user 42 not found", "caller": "db.go:88"}, "user <n> not found @ db.go:88"},
+		"error":   {j{"error": "timeout after 30s"}, "timeout after <n>s"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, stats.Fingerprint(tc.msg))
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	g := stats.NewGroups()
+	g.Record(j{"level": "info", "message": "user 1 logged in"})
+	g.Record(j{"level": "error", "message": "query 1 failed", "caller": "db.go:88", "time": "2022-08-03T12:34:28Z"})
+	g.Record(j{"level": "warn", "message": "slow query"})
+	g.Record(a{
+		j{"level": "fatal", "message": "shutting down"},
+		j{"level": "error", "message": "query 2 failed", "caller": "db.go:88", "time": "2022-08-03T12:34:20Z"},
+	})
+	g.Record(j{"level": "error", "message": "query 3 failed", "caller": "api.go:12"})
+
+	groups := g.Groups()
+	require.Len(t, groups, 3)
+
+	require.Equal(t, "query <n> failed @ db.go:88", groups[0].Fingerprint)
+	require.Equal(t, 2, groups[0].Count)
+	require.Equal(t, time.Date(2022, 8, 3, 12, 34, 20, 0, time.UTC), groups[0].First)
+	require.Equal(t, time.Date(2022, 8, 3, 12, 34, 28, 0, time.UTC), groups[0].Last)
+	require.Equal(t, "query 1 failed", groups[0].Example["message"])
+
+	require.Equal(t, "shutting down", groups[1].Fingerprint)
+	require.Equal(t, "query <n> failed @ api.go:12", groups[2].Fingerprint)
+	require.True(t, groups[2].First.IsZero())
+}
@@ -0,0 +1,241 @@
+package stats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultInterval is the width of a Rate bucket.
+	DefaultInterval = time.Minute
+
+	// maxRows bounds the rows written, empty buckets are skipped when filling
+	// every interval would write more.
+	maxRows = 1000
+)
+
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Rate counts records per level in buckets of time. It is safe for
+// concurrent use.
+type Rate struct {
+	mu       sync.Mutex
+	interval time.Duration
+	buckets  map[int64]map[string]int
+	loc      *time.Location
+	untimed  int
+	dropped  int
+}
+
+// NewRate buckets records by interval, DefaultInterval if it is not positive.
+func NewRate(interval time.Duration) *Rate {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Rate{
+		interval: interval,
+		buckets:  make(map[int64]map[string]int),
+	}
+}
+
+// Record adds a decoded JSON value, arrays add each of their elements.
+// Records without a time are counted but not bucketed.
+func (r *Rate) Record(a any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.record(a)
+}
+
+func (r *Rate) record(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		t, ok := Time(v)
+		if !ok {
+			r.untimed++
+			return
+		}
+		if r.loc == nil {
+			r.loc = t.Location()
+		}
+		k := t.Truncate(r.interval).UnixNano()
+		b, ok := r.buckets[k]
+		if !ok {
+			if len(r.buckets) >= maxDistinct {
+				r.dropped++
+				return
+			}
+			b = make(map[string]int)
+			r.buckets[k] = b
+		}
+		b[Level(v)]++
+	case []any:
+		for _, vv := range v {
+			r.record(vv)
+		}
+	}
+}
+
+// Bucket is the number of records per level starting at Time.
+type Bucket struct {
+	Time   time.Time
+	Levels map[string]int
+}
+
+// Total returns the number of records in the bucket.
+func (b Bucket) Total() int {
+	n := 0
+	for _, c := range b.Levels {
+		n += c
+	}
+	return n
+}
+
+// Buckets returns the buckets in time order, including empty buckets between
+// the first and last record.
+func (r *Rate) Buckets() []Bucket {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.buckets) == 0 {
+		return nil
+	}
+
+	keys := make([]int64, 0, len(r.buckets))
+	for k := range r.buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	step := int64(r.interval)
+	if (keys[len(keys)-1]-keys[0])/step >= maxRows {
+		buckets := make([]Bucket, 0, len(keys))
+		for _, k := range keys {
+			buckets = append(buckets, r.bucket(k))
+		}
+		return buckets
+	}
+	var buckets []Bucket
+	for k := keys[0]; k <= keys[len(keys)-1]; k += step {
+		buckets = append(buckets, r.bucket(k))
+	}
+	return buckets
+}
+
+func (r *Rate) bucket(k int64) Bucket {
+	levels := make(map[string]int, len(r.buckets[k]))
+	for l, n := range r.buckets[k] {
+		levels[l] = n
+	}
+	return Bucket{Time: time.Unix(0, k).In(r.loc), Levels: levels}
+}
+
+// Untimed returns the number of records without a time.
+func (r *Rate) Untimed() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.untimed
+}
+
+// Dropped returns the number of records not counted because they fall in none
+// of the buckets tracked, once maxDistinct buckets are.
+func (r *Rate) Dropped() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.dropped
+}
+
+// Write writes a table of the counts per bucket and level with a bar of the
+// total, followed by a sparkline of each level.
+func (r *Rate) Write(w io.Writer) error {
+	buckets := r.Buckets()
+	if len(buckets) == 0 {
+		_, err := fmt.Fprintf(w, "no timed records, %d without a time\n", r.Untimed())
+		return err
+	}
+
+	seen := make(map[string]int)
+	most := 0
+	for _, b := range buckets {
+		for l, n := range b.Levels {
+			seen[l] += n
+		}
+		if t := b.Total(); t > most {
+			most = t
+		}
+	}
+	levels := sortLevels(seen)
+
+	// the bars are written after the last column so trailing padding is trimmed
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "time\ttotal\t%s\t\n", strings.Join(levels, "\t"))
+	for _, b := range buckets {
+		fmt.Fprintf(tw, "%s\t%d\t", b.Time.Format(timeFormat(r.interval)), b.Total())
+		for _, l := range levels {
+			fmt.Fprintf(tw, "%d\t", b.Levels[l])
+		}
+		n := int(math.Round(float64(b.Total()) * barWidth / float64(most)))
+		fmt.Fprintf(tw, "%s\n", strings.Repeat(bar, n))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if _, err := fmt.Fprintln(w, strings.TrimRight(line, " ")); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, l := range levels {
+		counts := make([]int, len(buckets))
+		for i, b := range buckets {
+			counts[i] = b.Levels[l]
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", l, Sparkline(counts))
+	}
+	if u := r.Untimed(); u > 0 {
+		fmt.Fprintf(tw, "\n%d records without a time\n", u)
+	}
+	if d := r.Dropped(); d > 0 {
+		fmt.Fprintf(tw, "\n%d records past the first %d buckets not counted\n", d, maxDistinct)
+	}
+	return tw.Flush()
+}
+
+// timeFormat returns the format of bucket times, with fractional seconds for
+// sub-second intervals.
+func timeFormat(interval time.Duration) string {
+	if interval%time.Second != 0 {
+		return time.RFC3339Nano
+	}
+	return time.RFC3339
+}
+
+// Sparkline renders counts as a line of block characters scaled to the
+// largest count, zero counts are blank.
+func Sparkline(counts []int) string {
+	most := 0
+	for _, c := range counts {
+		if c > most {
+			most = c
+		}
+	}
+	var sb strings.Builder
+	for _, c := range counts {
+		if c == 0 {
+			sb.WriteRune(' ')
+			continue
+		}
+		i := int(math.Ceil(float64(c)*float64(len(sparks))/float64(most))) - 1
+		sb.WriteRune(sparks[i])
+	}
+	return strings.TrimRight(sb.String(), " ")
+}
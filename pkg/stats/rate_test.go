@@ -0,0 +1,84 @@
+package stats_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+var rateRecords = []any{
+	j{"level": "info", "time": "2022-08-03T12:34:05Z"},
+	j{"level": "info", "time": "2022-08-03T12:34:40Z"},
+	j{"level": "error", "time": "2022-08-03T12:35:10Z"},
+	a{
+		j{"level": "info", "time": "2022-08-03T12:37:00Z"},
+		j{"level": "error", "time": "2022-08-03T12:37:01Z"},
+	},
+	j{"level": "error", "time": "2022-08-03T12:37:59Z"},
+	j{"level": "warn", "message": "no time"},
+}
+
+func TestRate(t *testing.T) {
+	r := stats.NewRate(time.Minute)
+	for _, rec := range rateRecords {
+		r.Record(rec)
+	}
+	buckets := r.Buckets()
+	require.Len(t, buckets, 4)
+	require.Equal(t, time.Date(2022, 8, 3, 12, 34, 0, 0, time.UTC), buckets[0].Time.UTC())
+	require.Equal(t, map[string]int{"info": 2}, buckets[0].Levels)
+	require.Equal(t, 1, buckets[1].Total())
+	require.Equal(t, 0, buckets[2].Total())
+	require.Equal(t, map[string]int{"info": 1, "error": 2}, buckets[3].Levels)
+	require.Equal(t, 1, r.Untimed())
+}
+
+func TestRate_Dropped(t *testing.T) {
+	r := stats.NewRate(time.Second)
+	start := time.Date(2022, 8, 3, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 10002; i++ {
+		r.Record(j{"level": "info", "time": start.Add(time.Duration(i) * time.Second).Format(time.RFC3339)})
+	}
+	require.Equal(t, 2, r.Dropped())
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, r.Write(buf))
+	require.Contains(t, buf.String(), "2 records past the first 10000 buckets not counted\n")
+}
+
+func TestRate_Write(t *testing.T) {
+	testcases := map[string]struct {
+		interval time.Duration
+		records  []any
+	}{
+		"minute":  {time.Minute, rateRecords},
+		"seconds": {30 * time.Second, rateRecords},
+		"subsecond": {500 * time.Millisecond, []any{
+			j{"level": "info", "time": "2022-08-03T12:34:05.1Z"},
+			j{"level": "info", "time": "2022-08-03T12:34:05.7Z"},
+			j{"level": "warn", "time": "2022-08-03T12:34:06.9Z"},
+		}},
+		"none": {time.Minute, []any{j{"level": "info"}}},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			r := stats.NewRate(tc.interval)
+			for _, rec := range tc.records {
+				r.Record(rec)
+			}
+			buf := new(bytes.Buffer)
+			require.NoError(t, r.Write(buf))
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	require.Equal(t, "", stats.Sparkline(nil))
+	require.Equal(t, "▁▂▃▄▅▆▇█", stats.Sparkline([]int{1, 2, 3, 4, 5, 6, 7, 8}))
+	require.Equal(t, "█ ▄", stats.Sparkline([]int{10, 0, 5, 0}))
+}
@@ -0,0 +1,220 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// DefaultAccuracy is the relative accuracy of Sketch quantiles.
+	DefaultAccuracy = 0.01
+
+	// maxBuckets bounds the buckets kept for each sign.
+	maxBuckets = 2048
+)
+
+// Sketch summarizes a stream of numbers in bounded memory. Quantiles are
+// accurate to within DefaultAccuracy of the true value, see DDSketch
+// (https://arxiv.org/abs/1908.10693). It is not safe for concurrent use.
+type Sketch struct {
+	gamma    float64
+	logGamma float64
+	pos      *buckets
+	neg      *buckets
+	zero     int
+	count    int
+	sum      float64
+	min      float64
+	max      float64
+}
+
+func NewSketch() *Sketch {
+	gamma := (1 + DefaultAccuracy) / (1 - DefaultAccuracy)
+	return &Sketch{
+		gamma:    gamma,
+		logGamma: math.Log(gamma),
+		pos:      newBuckets(),
+		neg:      newBuckets(),
+	}
+}
+
+// Add records v, NaN and infinite values are ignored.
+func (s *Sketch) Add(v float64) {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return
+	}
+	if s.count == 0 || v < s.min {
+		s.min = v
+	}
+	if s.count == 0 || v > s.max {
+		s.max = v
+	}
+	s.count++
+	s.sum += v
+
+	switch {
+	case v > 0:
+		s.pos.add(s.index(v))
+	case v < 0:
+		s.neg.add(s.index(-v))
+	default:
+		s.zero++
+	}
+}
+
+func (s *Sketch) Count() int {
+	return s.count
+}
+
+func (s *Sketch) Min() float64 {
+	return s.min
+}
+
+func (s *Sketch) Max() float64 {
+	return s.max
+}
+
+func (s *Sketch) Mean() float64 {
+	if s.count == 0 {
+		return 0
+	}
+	return s.sum / float64(s.count)
+}
+
+// Quantile returns an estimate of the q quantile, 0 <= q <= 1, using the
+// nearest rank.
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(q*float64(s.count))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	v := s.max
+	s.each(func(value float64, n int) bool {
+		if rank < n {
+			v = value
+			return false
+		}
+		rank -= n
+		return true
+	})
+	return math.Max(s.min, math.Min(s.max, v))
+}
+
+// Bin is a range of values and how many were recorded in it.
+type Bin struct {
+	Low   float64
+	High  float64
+	Count int
+}
+
+// Histogram returns n equal width bins spanning the recorded values.
+func (s *Sketch) Histogram(n int) []Bin {
+	if s.count == 0 || n <= 0 {
+		return nil
+	}
+	if s.min == s.max {
+		n = 1
+	}
+	width := (s.max - s.min) / float64(n)
+	bins := make([]Bin, n)
+	for i := range bins {
+		bins[i].Low = s.min + float64(i)*width
+		bins[i].High = s.min + float64(i+1)*width
+	}
+	s.each(func(value float64, c int) bool {
+		i := n - 1
+		if width > 0 {
+			i = int((value - s.min) / width)
+		}
+		if i < 0 {
+			i = 0
+		}
+		if i >= n {
+			i = n - 1
+		}
+		bins[i].Count += c
+		return true
+	})
+	return bins
+}
+
+// each calls fn with the value and count of every bucket in increasing order
+// until fn returns false.
+func (s *Sketch) each(fn func(float64, int) bool) {
+	for _, i := range s.neg.keys(true) {
+		if !fn(-s.value(i), s.neg.counts[i]) {
+			return
+		}
+	}
+	if s.zero > 0 && !fn(0, s.zero) {
+		return
+	}
+	for _, i := range s.pos.keys(false) {
+		if !fn(s.value(i), s.pos.counts[i]) {
+			return
+		}
+	}
+}
+
+// index returns the bucket of the magnitude v.
+func (s *Sketch) index(v float64) int {
+	return int(math.Ceil(math.Log(v) / s.logGamma))
+}
+
+// value returns the representative magnitude of bucket i.
+func (s *Sketch) value(i int) float64 {
+	return 2 * math.Pow(s.gamma, float64(i)) / (s.gamma + 1)
+}
+
+// buckets counts values by bucket index. Once there are too many buckets the
+// lowest are merged, losing accuracy for the smallest magnitudes.
+type buckets struct {
+	counts map[int]int
+	// floor is the lowest bucket kept once merging has started
+	floor     int
+	collapsed bool
+}
+
+func newBuckets() *buckets {
+	return &buckets{
+		counts: make(map[int]int),
+	}
+}
+
+func (b *buckets) add(i int) {
+	if b.collapsed && i < b.floor {
+		i = b.floor
+	}
+	b.counts[i]++
+	if len(b.counts) <= maxBuckets {
+		return
+	}
+
+	// merge the lowest half of the buckets into one
+	k := b.keys(false)
+	b.floor = k[len(k)-maxBuckets/2]
+	b.collapsed = true
+	for _, j := range k {
+		if j >= b.floor {
+			break
+		}
+		b.counts[b.floor] += b.counts[j]
+		delete(b.counts, j)
+	}
+}
+
+func (b *buckets) keys(reverse bool) []int {
+	k := make([]int, 0, len(b.counts))
+	for i := range b.counts {
+		k = append(k, i)
+	}
+	if reverse {
+		sort.Sort(sort.Reverse(sort.IntSlice(k)))
+	} else {
+		sort.Ints(k)
+	}
+	return k
+}
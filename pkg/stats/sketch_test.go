@@ -0,0 +1,66 @@
+package stats_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch(t *testing.T) {
+	s := stats.NewSketch()
+	for i := 1; i <= 10000; i++ {
+		s.Add(float64(i))
+	}
+	s.Add(math.NaN())
+	s.Add(math.Inf(1))
+
+	require.Equal(t, 10000, s.Count())
+	require.Equal(t, 1.0, s.Min())
+	require.Equal(t, 10000.0, s.Max())
+	require.Equal(t, 5000.5, s.Mean())
+	for _, q := range []float64{0, 0.5, 0.9, 0.99, 1} {
+		expect := math.Max(1, math.Ceil(q*10000))
+		require.InEpsilon(t, expect, s.Quantile(q), stats.DefaultAccuracy, "q%v", q)
+	}
+}
+
+func TestSketch_Signs(t *testing.T) {
+	s := stats.NewSketch()
+	for _, v := range []float64{-100, -10, 0, 0, 10, 100} {
+		s.Add(v)
+	}
+	require.Equal(t, -100.0, s.Quantile(0))
+	require.InEpsilon(t, -10, s.Quantile(0.2), stats.DefaultAccuracy)
+	require.Equal(t, 0.0, s.Quantile(0.5))
+	require.InEpsilon(t, 10, s.Quantile(0.8), stats.DefaultAccuracy)
+	require.Equal(t, 100.0, s.Quantile(1))
+}
+
+func TestSketch_Bounded(t *testing.T) {
+	s := stats.NewSketch()
+	for i := -3000; i <= 3000; i++ {
+		s.Add(math.Pow(10, float64(i)/20))
+	}
+	// the largest values keep their accuracy when the smallest are merged
+	require.InEpsilon(t, 1e150, s.Quantile(1), stats.DefaultAccuracy)
+	require.InEpsilon(t, 1e147, s.Quantile(0.99), stats.DefaultAccuracy)
+}
+
+func TestSketch_Histogram(t *testing.T) {
+	s := stats.NewSketch()
+	require.Nil(t, s.Histogram(4))
+
+	for _, v := range []float64{0, 1, 2, 3, 4, 5, 6, 7, 8, 100} {
+		s.Add(v)
+	}
+	bins := s.Histogram(4)
+	require.Len(t, bins, 4)
+	require.Equal(t, stats.Bin{Low: 0, High: 25, Count: 9}, bins[0])
+	require.Equal(t, stats.Bin{Low: 75, High: 100, Count: 1}, bins[3])
+
+	one := stats.NewSketch()
+	one.Add(7)
+	require.Equal(t, []stats.Bin{{Low: 7, High: 7, Count: 1}}, one.Histogram(4))
+}
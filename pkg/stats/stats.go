@@ -0,0 +1,308 @@
+package stats
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/dcilke/gu"
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+const (
+	// NoLevel labels records without a level.
+	NoLevel = "<none>"
+	// DefaultTop is the number of top messages and errors reported.
+	DefaultTop = 5
+
+	// maxDistinct bounds the distinct messages and errors tracked, values first
+	// seen after the limit is reached are not counted.
+	maxDistinct = 10000
+)
+
+// levelOrder is the order known levels are reported in.
+var levelOrder = []string{
+	formatter.LevelTraceStr,
+	formatter.LevelDebugStr,
+	formatter.LevelInfoStr,
+	formatter.LevelWarnStr,
+	formatter.LevelErrorStr,
+	formatter.LevelFatalStr,
+	formatter.LevelPanicStr,
+}
+
+// Stats accumulates summary statistics over a stream of records. It is safe
+// for concurrent use.
+type Stats struct {
+	mu       sync.Mutex
+	levels   map[string]int
+	messages map[string]int
+	errors   map[string]int
+	first    time.Time
+	last     time.Time
+	records  int
+	lines    int
+	inLine   bool
+	top      int
+}
+
+// Option configures Stats.
+type Option func(*Stats)
+
+// WithTop sets the number of top messages and errors reported.
+func WithTop(n int) Option {
+	return func(s *Stats) {
+		s.top = n
+	}
+}
+
+func New(opts ...Option) *Stats {
+	s := &Stats{
+		levels:   make(map[string]int, len(levelOrder)),
+		messages: make(map[string]int),
+		errors:   make(map[string]int),
+		top:      DefaultTop,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Record counts a decoded JSON value, arrays count each of their elements.
+func (s *Stats) Record(a any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(a)
+}
+
+func (s *Stats) record(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		s.records++
+		s.levels[Level(v)]++
+		if msg, ok := value(v, formatter.KeyMessage, formatter.KeyMsg); ok {
+			count(s.messages, msg)
+		}
+		if err, ok := value(v, formatter.KeyError, formatter.KeyErr); ok {
+			count(s.errors, err)
+		}
+		if t, ok := Time(v); ok {
+			if s.first.IsZero() || t.Before(s.first) {
+				s.first = t
+			}
+			if t.After(s.last) {
+				s.last = t
+			}
+		}
+	case []any:
+		for _, vv := range v {
+			s.record(vv)
+		}
+	case nil:
+	default:
+		s.records++
+		s.levels[NoLevel]++
+	}
+}
+
+// Text counts the non-blank lines of non-JSON output, which may arrive in
+// chunks.
+func (s *Stats) Text(b []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range b {
+		switch c {
+		case '\n':
+			if s.inLine {
+				s.lines++
+			}
+			s.inLine = false
+		case ' ', '\t', '\r':
+		default:
+			s.inLine = true
+		}
+	}
+}
+
+// Records returns the number of JSON records seen.
+func (s *Stats) Records() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.records
+}
+
+// Lines returns the number of non-JSON lines seen.
+func (s *Stats) Lines() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inLine {
+		return s.lines + 1
+	}
+	return s.lines
+}
+
+// Levels returns the number of records seen per level.
+func (s *Stats) Levels() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	levels := make(map[string]int, len(s.levels))
+	for k, v := range s.levels {
+		levels[k] = v
+	}
+	return levels
+}
+
+// Span returns the earliest and latest record times seen, both are zero if no
+// record had a time.
+func (s *Stats) Span() (time.Time, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.first, s.last
+}
+
+// Messages returns the most frequent messages, most frequent first.
+func (s *Stats) Messages() []Count {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return top(s.messages, s.top)
+}
+
+// Errors returns the most frequent error values, most frequent first.
+func (s *Stats) Errors() []Count {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return top(s.errors, s.top)
+}
+
+// Write writes the summary to w.
+func (s *Stats) Write(w io.Writer) error {
+	levels := s.Levels()
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "json\t%d\n", s.Records())
+	fmt.Fprintf(tw, "text\t%d\n", s.Lines())
+	if first, last := s.Span(); !first.IsZero() {
+		fmt.Fprintf(tw, "span\t%s - %s (%s)\n", first.Format(time.RFC3339), last.Format(time.RFC3339), last.Sub(first).Round(time.Millisecond))
+	}
+	if len(levels) > 0 {
+		fmt.Fprintln(tw, "levels")
+		for _, l := range sortLevels(levels) {
+			fmt.Fprintf(tw, "  %s\t%d\n", l, levels[l])
+		}
+	}
+	writeCounts(tw, "messages", s.Messages())
+	writeCounts(tw, "errors", s.Errors())
+	return tw.Flush()
+}
+
+func writeCounts(w io.Writer, title string, counts []Count) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintln(w, title)
+	for _, c := range counts {
+		fmt.Fprintf(w, "  %d\t%s\n", c.N, c.Value)
+	}
+}
+
+// Count is the number of times a value was seen.
+type Count struct {
+	Value string
+	N     int
+}
+
+// top returns the n most frequent values of counts, ties ordered by value.
+func top(counts map[string]int, n int) []Count {
+	all := make([]Count, 0, len(counts))
+	for v, c := range counts {
+		all = append(all, Count{Value: v, N: c})
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].N != all[j].N {
+			return all[i].N > all[j].N
+		}
+		return all[i].Value < all[j].Value
+	})
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+func count(counts map[string]int, v string) {
+	if _, ok := counts[v]; ok || len(counts) < maxDistinct {
+		counts[v]++
+	}
+}
+
+// value returns the first of keys present in m as a string.
+func value(m map[string]any, keys ...string) (string, bool) {
+	for _, k := range keys {
+		if v, ok := m[k]; ok && v != nil {
+			return String(v), true
+		}
+	}
+	return "", false
+}
+
+// String returns v as it is counted, strings and numbers as they are and
+// anything else as JSON.
+func String(v any) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case json.Number:
+		return vv.String()
+	default:
+		b, err := json.Marshal(vv)
+		if err != nil {
+			return fmt.Sprint(vv)
+		}
+		return string(b)
+	}
+}
+
+// Time returns the time of the record from the timestamp, @timestamp or time
+// key, read as the timestamp pin reads it.
+func Time(m map[string]any) (time.Time, bool) {
+	for _, k := range []string{formatter.KeyTimestamp, formatter.KeyAtTimestamp, formatter.KeyTime} {
+		if t, ok := formatter.ParseTime(m[k]); ok {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Level returns the level of the record, preferring level over log.level.
+func Level(m map[string]any) string {
+	levels := formatter.GetLevels(m)
+	if l := levels[formatter.KeyLevel]; l != "" {
+		return l
+	}
+	if l := levels[formatter.KeyLog]; l != "" {
+		return l
+	}
+	return NoLevel
+}
+
+// sortLevels orders known levels by severity followed by the rest by name.
+func sortLevels(levels map[string]int) []string {
+	keys := make([]string, 0, len(levels))
+	for _, l := range levelOrder {
+		if _, ok := levels[l]; ok {
+			keys = append(keys, l)
+		}
+	}
+	var rest []string
+	for l := range levels {
+		if !gu.Includes(levelOrder, l) {
+			rest = append(rest, l)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
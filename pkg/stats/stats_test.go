@@ -0,0 +1,104 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+type a = []any
+
+func TestStats(t *testing.T) {
+	s := stats.New()
+	s.Record(j{"level": "info", "message": "started", "time": "2022-08-03T12:34:25Z"})
+	s.Record(j{"level": "error", "message": "failed", "error": "timeout", "time": "2022-08-03T12:30:00Z"})
+	s.Record(j{"log": j{"level": "warn"}})
+	s.Record(j{"level": json.Number("30")})
+	s.Record(j{"level": "notice", "err": j{"code": json.Number("7")}})
+	s.Record(j{"msg": "no level", "time": json.Number("1659530400")})
+	s.Record(a{j{"level": "debug", "message": "failed"}, j{"level": "info", "message": "started", "error": "timeout"}})
+	s.Record(nil)
+	s.Text([]byte("plain text\n\n  \nsplit "))
+	s.Text([]byte("line\nlast"))
+
+	require.Equal(t, 8, s.Records())
+	require.Equal(t, 3, s.Lines())
+	require.Equal(t, map[string]int{
+		"debug":       1,
+		"info":        3,
+		"warn":        1,
+		"error":       1,
+		"notice":      1,
+		stats.NoLevel: 1,
+	}, s.Levels())
+
+	first, last := s.Span()
+	require.Equal(t, time.Date(2022, 8, 3, 12, 30, 0, 0, time.UTC), first)
+	require.Equal(t, time.Date(2022, 8, 3, 12, 40, 0, 0, time.UTC), last)
+	require.Equal(t, []stats.Count{{"failed", 2}, {"started", 2}, {"no level", 1}}, s.Messages())
+	require.Equal(t, []stats.Count{{"timeout", 2}, {`{"code":7}`, 1}}, s.Errors())
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, s.Write(buf))
+	golden.Assert(t, buf.Bytes())
+}
+
+func TestLevel(t *testing.T) {
+	testcases := map[string]struct {
+		msg    map[string]any
+		expect string
+	}{
+		"level":     {j{"level": "info"}, "info"},
+		"log.level": {j{"log": j{"level": "warn"}}, "warn"},
+		"number":    {j{"level": json.Number("50")}, "error"},
+		"both":      {j{"level": "info", "log": j{"level": "warn"}}, "info"},
+		"none":      {j{"msg": "hi"}, stats.NoLevel},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, tc.expect, stats.Level(tc.msg))
+		})
+	}
+}
+
+func TestStats_Top(t *testing.T) {
+	s := stats.New(stats.WithTop(2))
+	for _, msg := range []string{"a", "b", "b", "c", "c", "c"} {
+		s.Record(j{"message": msg})
+	}
+	require.Equal(t, []stats.Count{{"c", 3}, {"b", 2}}, s.Messages())
+	require.Empty(t, s.Errors())
+}
+
+func TestTime(t *testing.T) {
+	at := time.Date(2022, 8, 3, 12, 34, 25, 0, time.UTC)
+	testcases := map[string]struct {
+		msg    map[string]any
+		expect time.Time
+		ok     bool
+	}{
+		"time":       {j{"time": "2022-08-03T12:34:25Z"}, at, true},
+		"timestamp":  {j{"timestamp": "2022-08-03T12:34:25Z"}, at, true},
+		"@timestamp": {j{"@timestamp": "2022-08-03T12:34:25Z"}, at, true},
+		"unix":       {j{"time": json.Number("1659530065")}, at, true},
+		"unix-ms":    {j{"time": json.Number("1659530065000")}, at, true},
+		"unix-us":    {j{"time": json.Number("1659530065000000")}, at, true},
+		"unix-ns":    {j{"time": json.Number("1659530065000000000")}, at, true},
+		"unix-float": {j{"time": json.Number("1659530065.0")}, at, true},
+		"invalid":    {j{"time": "yesterday"}, time.Time{}, false},
+		"none":       {j{"msg": "hi"}, time.Time{}, false},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			ts, ok := stats.Time(tc.msg)
+			require.Equal(t, tc.ok, ok)
+			require.True(t, tc.expect.Equal(ts), ts)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+const (
+	// NoValue labels records without the counted field.
+	NoValue = "<missing>"
+	// Other labels the records counted together once maxDistinct values or
+	// groups are tracked.
+	Other = "(other)"
+)
+
+// Top counts the values of a field, optionally per level. It is safe for
+// concurrent use.
+type Top struct {
+	mu      sync.Mutex
+	field   string
+	levels  []string
+	byLevel bool
+	limit   int
+	counts  map[string]map[string]int
+	totals  map[string]int
+}
+
+// TopOption configures Top.
+type TopOption func(*Top)
+
+// WithLevels only counts records at one of levels.
+func WithLevels(levels []string) TopOption {
+	return func(t *Top) {
+		t.levels = levels
+	}
+}
+
+// WithByLevel counts the values separately for each level.
+func WithByLevel(b bool) TopOption {
+	return func(t *Top) {
+		t.byLevel = b
+	}
+}
+
+// WithLimit sets the number of values reported per group, 0 reports all.
+func WithLimit(n int) TopOption {
+	return func(t *Top) {
+		t.limit = n
+	}
+}
+
+// NewTop counts the values of field, which may be a dotted path.
+func NewTop(field string, opts ...TopOption) *Top {
+	t := &Top{
+		field:  field,
+		counts: make(map[string]map[string]int),
+		totals: make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Record counts a decoded JSON value, arrays count each of their elements.
+func (t *Top) Record(a any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record(a)
+}
+
+func (t *Top) record(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		if !formatter.MatchLevels(v, t.levels) {
+			return
+		}
+		group := ""
+		if t.byLevel {
+			group = Level(v)
+		}
+		val := NoValue
+		if f, ok := formatter.Lookup(v, t.field); ok && f != nil {
+			val = String(f)
+		}
+		counts, ok := t.counts[group]
+		if !ok {
+			counts = make(map[string]int)
+			t.counts[group] = counts
+		}
+		if _, ok := counts[val]; !ok && len(counts) >= maxDistinct {
+			val = Other
+		}
+		counts[val]++
+		t.totals[group]++
+	case []any:
+		for _, vv := range v {
+			t.record(vv)
+		}
+	}
+}
+
+// Counts returns the most frequent values of the group, which is the level
+// when counting by level and "" otherwise.
+func (t *Top) Counts(group string) []Count {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := t.limit
+	if n <= 0 {
+		n = len(t.counts[group])
+	}
+	return top(t.counts[group], n)
+}
+
+// Total returns the number of records counted in the group.
+func (t *Top) Total(group string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.totals[group]
+}
+
+// Write writes the report to w.
+func (t *Top) Write(w io.Writer) error {
+	t.mu.Lock()
+	groups := sortLevels(t.totals)
+	t.mu.Unlock()
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, g := range groups {
+		indent := ""
+		if t.byLevel {
+			fmt.Fprintln(tw, g)
+			indent = "  "
+		}
+		total := t.Total(g)
+		for _, c := range t.Counts(g) {
+			fmt.Fprintf(tw, "%s%d\t%5.1f%%\t%s\n", indent, c.N, 100*float64(c.N)/float64(total), c.Value)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,75 @@
+package stats_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/dcilke/golden"
+	"github.com/dcilke/hz/pkg/stats"
+	"github.com/stretchr/testify/require"
+)
+
+var topRecords = []any{
+	j{"level": "warn", "request": j{"path": "/api/users"}},
+	j{"level": "warn", "request": j{"path": "/api/users"}},
+	j{"level": "error", "request": j{"path": "/api/orders"}},
+	a{
+		j{"level": "info", "request": j{"path": "/api/users"}},
+		j{"level": "warn", "request": j{"path": "/api/orders"}},
+	},
+	j{"level": "warn", "request": j{"status": json.Number("200")}},
+}
+
+func TestTop(t *testing.T) {
+	testcases := map[string]struct {
+		field  string
+		opts   []stats.TopOption
+		expect []stats.Count
+	}{
+		"nested": {"request.path", nil, []stats.Count{{"/api/users", 3}, {"/api/orders", 2}, {stats.NoValue, 1}}},
+		"number": {"request.status", nil, []stats.Count{{stats.NoValue, 5}, {"200", 1}}},
+		"levels": {"request.path", []stats.TopOption{stats.WithLevels([]string{"error"})}, []stats.Count{{"/api/orders", 1}}},
+		"limit":  {"request.path", []stats.TopOption{stats.WithLimit(1)}, []stats.Count{{"/api/users", 3}}},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			top := stats.NewTop(tc.field, tc.opts...)
+			for _, r := range topRecords {
+				top.Record(r)
+			}
+			require.Equal(t, tc.expect, top.Counts(""))
+		})
+	}
+}
+
+func TestTop_Other(t *testing.T) {
+	top := stats.NewTop("id")
+	for i := 0; i < 10002; i++ {
+		top.Record(j{"id": strconv.Itoa(i % 10001)})
+	}
+	counts := top.Counts("")
+	require.Len(t, counts, 10001)
+	require.Equal(t, stats.Count{Value: "0", N: 2}, counts[0])
+	require.Contains(t, counts, stats.Count{Value: stats.Other, N: 1})
+	require.Equal(t, 10002, top.Total(""))
+}
+
+func TestTop_Write(t *testing.T) {
+	testcases := map[string][]stats.TopOption{
+		"all":      nil,
+		"by-level": {stats.WithByLevel(true)},
+	}
+	for name, opts := range testcases {
+		t.Run(name, func(t *testing.T) {
+			top := stats.NewTop("request.path", opts...)
+			for _, r := range topRecords {
+				top.Record(r)
+			}
+			buf := new(bytes.Buffer)
+			require.NoError(t, top.Write(buf))
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
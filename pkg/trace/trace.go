@@ -0,0 +1,303 @@
+// Package trace groups records by the trace and span they were logged in, so a
+// trace can be shown as a tree of spans from log files alone.
+//
+// Records are matched by their trace_id, span_id and parent_span_id fields, or
+// the camel case and dotted forms OpenTelemetry and Elastic Common Schema use.
+// A span's parent is read from any of its records.
+package trace
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dcilke/hz/pkg/stats"
+)
+
+// maxTraces bounds the traces buffered, records of traces first seen after the
+// limit is reached are dropped.
+const maxTraces = 10000
+
+var (
+	// TraceKeys are the fields holding the trace id of a record, in order.
+	TraceKeys = []string{"trace_id", "traceId", "trace.id"}
+	// SpanKeys are the fields holding the span id of a record, in order.
+	SpanKeys = []string{"span_id", "spanId", "span.id"}
+	// ParentKeys are the fields holding the parent span id of a record, in
+	// order.
+	ParentKeys = []string{"parent_span_id", "parentSpanId", "parent_id", "parent.id"}
+)
+
+// Trace is the records of a trace, by span.
+type Trace struct {
+	ID string
+	// Roots are the spans whose parent was not seen, by start time.
+	Roots []*Span
+	// Records are the records of the trace without a span.
+	Records []map[string]any
+	// Start and End are the first and last time of the trace's records.
+	Start time.Time
+	End   time.Time
+
+	spans map[string]*Span
+	seq   int
+}
+
+// Duration returns the time between the first and last record of the trace.
+func (t *Trace) Duration() time.Duration {
+	return t.End.Sub(t.Start)
+}
+
+// Spans returns the number of spans in the trace.
+func (t *Trace) Spans() int {
+	return len(t.spans)
+}
+
+// Span is the records logged in a span.
+type Span struct {
+	ID       string
+	ParentID string
+	Records  []map[string]any
+	// Children are the spans whose parent is this span, by start time.
+	Children []*Span
+	// Start and End are the first and last time of the records of the span
+	// and its children.
+	Start time.Time
+	End   time.Time
+
+	seq int
+}
+
+// Duration returns the time between the first and last record of the span and
+// its children.
+func (s *Span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// Traces buffers records by trace. It is safe for concurrent use.
+type Traces struct {
+	mu     sync.Mutex
+	traces map[string]*Trace
+}
+
+func New() *Traces {
+	return &Traces{
+		traces: make(map[string]*Trace),
+	}
+}
+
+// Record adds a decoded JSON value, arrays add each of their elements. Records
+// without a trace id are ignored.
+func (t *Traces) Record(a any) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.record(a)
+}
+
+func (t *Traces) record(a any) {
+	switch v := a.(type) {
+	case map[string]any:
+		id, ok := lookup(v, TraceKeys)
+		if !ok {
+			return
+		}
+		tr, ok := t.traces[id]
+		if !ok {
+			if len(t.traces) >= maxTraces {
+				return
+			}
+			tr = &Trace{ID: id, spans: make(map[string]*Span), seq: len(t.traces)}
+			t.traces[id] = tr
+		}
+		ts, timed := stats.Time(v)
+		if timed {
+			tr.Start, tr.End = extend(tr.Start, tr.End, ts)
+		}
+
+		sid, ok := lookup(v, SpanKeys)
+		if !ok {
+			tr.Records = append(tr.Records, v)
+			return
+		}
+		sp, ok := tr.spans[sid]
+		if !ok {
+			sp = &Span{ID: sid, seq: len(tr.spans)}
+			tr.spans[sid] = sp
+		}
+		if sp.ParentID == "" {
+			sp.ParentID, _ = lookup(v, ParentKeys)
+		}
+		sp.Records = append(sp.Records, v)
+		if timed {
+			sp.Start, sp.End = extend(sp.Start, sp.End, ts)
+		}
+	case []any:
+		for _, vv := range v {
+			t.record(vv)
+		}
+	}
+}
+
+// Traces returns the traces with their spans linked into trees, by start time
+// and then in the order they were first seen. Traces without a time come last.
+func (t *Traces) Traces() []*Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	traces := make([]*Trace, 0, len(t.traces))
+	for _, tr := range t.traces {
+		link(tr)
+		traces = append(traces, tr)
+	}
+	sort.Slice(traces, func(i, j int) bool {
+		return before(traces[i].Start, traces[i].seq, traces[j].Start, traces[j].seq)
+	})
+	return traces
+}
+
+// link builds the span tree of tr. Spans whose parent was not seen, or which
+// are only reachable through a cycle of parents, are roots.
+func link(tr *Trace) {
+	tr.Roots = nil
+	spans := make([]*Span, 0, len(tr.spans))
+	for _, sp := range tr.spans {
+		sp.Children = nil
+		spans = append(spans, sp)
+	}
+	sortSpans(spans)
+
+	for _, sp := range spans {
+		if parent, ok := tr.spans[sp.ParentID]; ok && parent != sp {
+			parent.Children = append(parent.Children, sp)
+		} else {
+			tr.Roots = append(tr.Roots, sp)
+		}
+	}
+
+	seen := make(map[*Span]bool, len(spans))
+	for _, sp := range tr.Roots {
+		visit(sp, seen)
+	}
+	for _, sp := range spans {
+		if seen[sp] {
+			continue
+		}
+		// break the cycle at its first span
+		if parent, ok := tr.spans[sp.ParentID]; ok {
+			parent.Children = remove(parent.Children, sp)
+		}
+		tr.Roots = append(tr.Roots, sp)
+		visit(sp, seen)
+	}
+	sortSpans(tr.Roots)
+}
+
+// visit marks the spans below sp as seen and extends the time of each span
+// over its children.
+func visit(sp *Span, seen map[*Span]bool) {
+	seen[sp] = true
+	for _, c := range sp.Children {
+		visit(c, seen)
+		if !c.Start.IsZero() {
+			sp.Start, sp.End = extend(sp.Start, sp.End, c.Start)
+			sp.Start, sp.End = extend(sp.Start, sp.End, c.End)
+		}
+	}
+	sortSpans(sp.Children)
+}
+
+func remove(spans []*Span, sp *Span) []*Span {
+	for i, s := range spans {
+		if s == sp {
+			return append(spans[:i:i], spans[i+1:]...)
+		}
+	}
+	return spans
+}
+
+func sortSpans(spans []*Span) {
+	sort.Slice(spans, func(i, j int) bool {
+		return before(spans[i].Start, spans[i].seq, spans[j].Start, spans[j].seq)
+	})
+}
+
+// before orders by time and then by seq, with zero times last.
+func before(a time.Time, aseq int, b time.Time, bseq int) bool {
+	switch {
+	case a.IsZero() != b.IsZero():
+		return b.IsZero()
+	case !a.Equal(b):
+		return a.Before(b)
+	}
+	return aseq < bseq
+}
+
+// extend widens the range from start to end to include t.
+func extend(start time.Time, end time.Time, t time.Time) (time.Time, time.Time) {
+	if start.IsZero() || t.Before(start) {
+		start = t
+	}
+	if end.IsZero() || t.After(end) {
+		end = t
+	}
+	return start, end
+}
+
+// Strip returns a copy of m without the trace, span and parent span ids a tree
+// of the trace shows. Dotted keys are also removed from nested objects, which
+// are dropped if left empty.
+func Strip(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	for _, keys := range [][]string{TraceKeys, SpanKeys, ParentKeys} {
+		for _, k := range keys {
+			delete(out, k)
+			i := strings.IndexByte(k, '.')
+			if i <= 0 {
+				continue
+			}
+			sub, ok := out[k[:i]].(map[string]any)
+			if !ok {
+				continue
+			}
+			if _, ok := sub[k[i+1:]]; !ok {
+				continue
+			}
+			rest := make(map[string]any, len(sub))
+			for kk, v := range sub {
+				if kk != k[i+1:] {
+					rest[kk] = v
+				}
+			}
+			if len(rest) == 0 {
+				delete(out, k[:i])
+			} else {
+				out[k[:i]] = rest
+			}
+		}
+	}
+	return out
+}
+
+// lookup returns the first of keys present in m as a string. Dotted keys are
+// also found nested in objects, e.g. trace.id in {"trace": {"id": "..."}}.
+func lookup(m map[string]any, keys []string) (string, bool) {
+	for _, k := range keys {
+		v, ok := m[k]
+		if !ok {
+			if i := strings.IndexByte(k, '.'); i > 0 {
+				sub, _ := m[k[:i]].(map[string]any)
+				v, ok = sub[k[i+1:]]
+			}
+		}
+		if !ok || v == nil {
+			continue
+		}
+		if s := stats.String(v); s != "" {
+			return s, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,88 @@
+package trace_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dcilke/hz/pkg/trace"
+	"github.com/stretchr/testify/require"
+)
+
+type j = map[string]any
+type a = []any
+
+func ids(spans []*trace.Span) []string {
+	out := make([]string, len(spans))
+	for i, sp := range spans {
+		out[i] = sp.ID
+	}
+	return out
+}
+
+func TestTraces(t *testing.T) {
+	tr := trace.New()
+	tr.Record(j{"message": "no trace"})
+	tr.Record(j{"trace_id": "t1", "span_id": "db", "parent_span_id": "root", "time": "2022-08-03T12:34:21.5Z", "message": "query"})
+	tr.Record(j{"trace_id": "t1", "span_id": "root", "time": "2022-08-03T12:34:21Z", "message": "request"})
+	tr.Record(a{
+		j{"trace_id": "t1", "span_id": "cache", "parent_span_id": "root", "time": "2022-08-03T12:34:21.1Z"},
+		j{"trace_id": "t1", "span_id": "db", "time": "2022-08-03T12:34:22.25Z", "message": "rows"},
+	})
+	tr.Record(j{"trace_id": "t1", "message": "no span"})
+	tr.Record(j{"traceId": "t0", "spanId": "a", "parentSpanId": "gone", "time": "2022-08-03T12:34:20Z"})
+	tr.Record(j{"trace": j{"id": "t2"}, "span": j{"id": "x"}})
+
+	traces := tr.Traces()
+	require.Len(t, traces, 3)
+	require.Equal(t, "t0", traces[0].ID)
+	require.Equal(t, "t1", traces[1].ID)
+	require.Equal(t, "t2", traces[2].ID)
+
+	t1 := traces[1]
+	require.Equal(t, 3, t1.Spans())
+	require.Equal(t, 1250*time.Millisecond, t1.Duration())
+	require.Equal(t, []map[string]any{{"trace_id": "t1", "message": "no span"}}, t1.Records)
+	require.Equal(t, []string{"root"}, ids(t1.Roots))
+
+	root := t1.Roots[0]
+	require.Equal(t, []string{"cache", "db"}, ids(root.Children))
+	require.Equal(t, 1250*time.Millisecond, root.Duration())
+	require.Len(t, root.Records, 1)
+
+	db := root.Children[1]
+	require.Equal(t, "root", db.ParentID)
+	require.Equal(t, 750*time.Millisecond, db.Duration())
+	require.Len(t, db.Records, 2)
+
+	require.Equal(t, []string{"a"}, ids(traces[0].Roots))
+	require.Equal(t, []string{"x"}, ids(traces[2].Roots))
+	require.True(t, traces[2].Start.IsZero())
+}
+
+func TestTraces_Cycle(t *testing.T) {
+	tr := trace.New()
+	tr.Record(j{"trace_id": "t", "span_id": "a", "parent_span_id": "b", "time": "2022-08-03T12:34:20Z"})
+	tr.Record(j{"trace_id": "t", "span_id": "b", "parent_span_id": "a", "time": "2022-08-03T12:34:21Z"})
+	tr.Record(j{"trace_id": "t", "span_id": "c", "parent_span_id": "c", "time": "2022-08-03T12:34:22Z"})
+
+	traces := tr.Traces()
+	require.Len(t, traces, 1)
+	require.Equal(t, []string{"a", "c"}, ids(traces[0].Roots))
+	require.Equal(t, []string{"b"}, ids(traces[0].Roots[0].Children))
+	require.Empty(t, traces[0].Roots[1].Children)
+}
+
+func TestStrip(t *testing.T) {
+	m := j{
+		"trace_id": "t1",
+		"span.id":  "a",
+		"trace":    j{"id": "t1"},
+		"span":     j{"id": "a", "name": "GET /"},
+		"parent":   j{"id": "root"},
+		"message":  "hello",
+	}
+	require.Equal(t, j{"span": j{"name": "GET /"}, "message": "hello"}, trace.Strip(m))
+	// m is left as is
+	require.Equal(t, j{"id": "a", "name": "GET /"}, m["span"])
+	require.Len(t, m, 6)
+}
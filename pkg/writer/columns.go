@@ -0,0 +1,49 @@
+package writer
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/dcilke/hz/pkg/formatter"
+)
+
+// columns tracks the widths of aligned pins. Widths which are not fixed grow
+// to the widest value seen so far.
+type columns struct {
+	mu     sync.Mutex
+	fixed  map[string]int
+	widths map[string]int
+}
+
+func newColumns(fixed map[string]int) *columns {
+	c := &columns{
+		fixed:  make(map[string]int, len(fixed)),
+		widths: make(map[string]int, 4),
+	}
+	for k, v := range fixed {
+		c.fixed[k] = v
+	}
+	return c
+}
+
+// width returns the column width for pin p given a value n runes wide.
+func (c *columns) width(p string, n int) int {
+	if w, ok := c.fixed[p]; ok {
+		return w
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n > c.widths[p] {
+		c.widths[p] = n
+	}
+	return c.widths[p]
+}
+
+// pad right pads s to the width of pin p.
+func (c *columns) pad(p string, s string) string {
+	n := formatter.VisibleLen(s)
+	if w := c.width(p, n); n < w {
+		return s + strings.Repeat(string(defaultSep), w-n)
+	}
+	return s
+}
@@ -8,6 +8,8 @@ import (
 	"os"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/dcilke/gu"
@@ -21,9 +23,11 @@ const (
 	PinCaller    = "caller"
 	PinMessage   = "message"
 	PinError     = "error"
+	PinHTTP      = "http"
 
 	defaultTimeFormat = "15:04:05"
 	defaultSep        = ' '
+	defaultFlattenSep = "."
 	newline           = '\n'
 	tab               = '\t'
 )
@@ -49,6 +53,12 @@ var (
 
 // Writer parses the JSON input and writes it in an
 // (optionally) colorized, human-friendly format to Out.
+//
+// A Writer and its copies are safe for concurrent use. Each call formats its
+// input completely before writing it to Out in a single Write, so records,
+// including every element of an array, never interleave. Use WriteLine to
+// keep the newline ending a record in the same write. Writers are created
+// with New, a zero Writer does not serialize its writes.
 type Writer struct {
 	// out is the output destination.
 	out io.Writer
@@ -71,6 +81,10 @@ type Writer struct {
 	// excludeKeys defines contextual keys to not display in output.
 	excludeKeys []string
 
+	// recordKeys are the keys pins used for the record being formatted, they
+	// are hidden wherever they are nested.
+	recordKeys []string
+
 	// formatter defines a map of formatters for pins.
 	formatter map[string]formatter.Formatter
 
@@ -80,11 +94,41 @@ type Writer struct {
 	// formatKey defines the default key formatter.
 	formatKey formatter.Stringer
 
-	// flatten enables flattening of JSON objects.
+	// flatten enables flattening of JSON objects and arrays.
 	flatten bool
 
+	// flattenDepth limits how deep flattening recurses, 0 is unlimited.
+	flattenDepth int
+
+	// flattenSep joins the keys of flattened fields.
+	flattenSep string
+
 	// vertical enables vertical printing of JSON objects
 	vertical bool
+
+	// maxValueLen truncates field values longer than this many bytes, 0 is unlimited.
+	maxValueLen int
+
+	// wrap wraps output at this width with a hanging indent, 0 disables wrapping.
+	wrap int
+
+	// align pads the pins preceding the message to a stable width.
+	align bool
+
+	// columnWidths fixes the width of aligned pins, others are learned.
+	columnWidths map[string]int
+
+	// columns tracks aligned pin widths across records.
+	columns *columns
+
+	// humanize enables the default humanizers.
+	humanize bool
+
+	// humanizers defines humanizers for specific fields.
+	humanizers formatter.Humanizers
+
+	// mu serializes writes to out, it is shared by copies of the Writer.
+	mu *sync.Mutex
 }
 
 type Option func(w *Writer)
@@ -129,6 +173,23 @@ func WithPinOrder(order []string) Option {
 	}
 }
 
+// Pin p ahead of the before pin, or last if before is not pinned.
+func WithPinBefore(p string, before string) Option {
+	return func(w *Writer) {
+		order := make([]string, 0, len(w.pinOrder)+1)
+		for _, o := range w.pinOrder {
+			if o == before {
+				order = append(order, p)
+			}
+			order = append(order, o)
+		}
+		if !gu.Includes(order, p) {
+			order = append(order, p)
+		}
+		w.pinOrder = order
+	}
+}
+
 func WithFielder(f formatter.Fielder) Option {
 	return func(w *Writer) {
 		w.fielder = f
@@ -141,6 +202,16 @@ func WithExcludeKeys(keys []string) Option {
 	}
 }
 
+// WithExcludeKeysAppend leaves keys out of the fields in addition to the keys
+// excluded by earlier options, where WithExcludeKeys replaces them.
+func WithExcludeKeysAppend(keys []string) Option {
+	return func(w *Writer) {
+		// copy rather than append to a slice given to WithExcludeKeys
+		n := len(w.excludeKeys)
+		w.excludeKeys = append(w.excludeKeys[:n:n], keys...)
+	}
+}
+
 func WithKeyFormatter(f formatter.Stringer) Option {
 	return func(w *Writer) {
 		w.formatKey = f
@@ -165,12 +236,76 @@ func WithFlatten(b bool) Option {
 	}
 }
 
+// Limit flattening to n levels, values below are shown as JSON. Defaults to 0
+// (unlimited).
+func WithFlattenDepth(n int) Option {
+	return func(w *Writer) {
+		w.flattenDepth = n
+	}
+}
+
+// Override the flattened key separator, defaults to ".".
+func WithFlattenSeparator(sep string) Option {
+	return func(w *Writer) {
+		w.flattenSep = sep
+	}
+}
+
 func WithVertical(b bool) Option {
 	return func(w *Writer) {
 		w.vertical = b
 	}
 }
 
+// Truncate field values longer than n bytes, defaults to 0 (unlimited).
+func WithMaxValueLen(n int) Option {
+	return func(w *Writer) {
+		w.maxValueLen = n
+	}
+}
+
+// Wrap output at width columns, continuation lines are indented under the
+// message. Defaults to 0 (disabled).
+func WithWrap(width int) Option {
+	return func(w *Writer) {
+		w.wrap = width
+	}
+}
+
+// Align the pins preceding the message into columns.
+func WithAlign(b bool) Option {
+	return func(w *Writer) {
+		w.align = b
+	}
+}
+
+// Fix the width of an aligned pin, widths not set are learned from the input.
+func WithColumnWidth(pin string, width int) Option {
+	return func(w *Writer) {
+		if w.columnWidths == nil {
+			w.columnWidths = make(map[string]int, 4)
+		}
+		w.columnWidths[pin] = width
+	}
+}
+
+// Humanize durations, byte sizes and status codes by field naming conventions.
+func WithHumanize(b bool) Option {
+	return func(w *Writer) {
+		w.humanize = b
+	}
+}
+
+// Humanize the values of a specific field.
+func WithHumanizer(key string, h formatter.Humanizer) Option {
+	return func(w *Writer) {
+		if w.humanizers == nil {
+			w.humanizers = make(formatter.Humanizers, 4)
+		}
+		w.humanizers[key] = h
+	}
+}
+
 // New creates and initializes a new ConsoleWriter.
 func New(options ...Option) Writer {
 	w := Writer{
@@ -181,6 +316,8 @@ func New(options ...Option) Writer {
 		excludeKeys: make([]string, 0, 10),
 		formatter:   make(map[string]formatter.Formatter, 6),
 		flatten:     false,
+		flattenSep:  defaultFlattenSep,
+		mu:          &sync.Mutex{},
 	}
 
 	for _, opt := range options {
@@ -238,11 +375,56 @@ func New(options ...Option) Writer {
 		}
 	}
 
+	if _, ok := w.formatter[PinHTTP]; !ok {
+		f := formatter.NewHTTP(w.color)
+		w.formatter[PinHTTP] = f
+		if gu.Includes(w.pinOrder, PinHTTP) {
+			w.excludeKeys = append(w.excludeKeys, f.ExcludeKeys()...)
+		}
+	}
+
+	// Exclude the keys of other pinned formatters, e.g. plugins
+	for _, p := range w.pinOrder {
+		switch p {
+		case PinTimestamp, PinLevel, PinMessage, PinCaller, PinError, PinHTTP:
+			continue
+		}
+		if f, ok := w.formatter[p]; ok {
+			w.excludeKeys = append(w.excludeKeys, f.ExcludeKeys()...)
+		}
+	}
+
 	// Ensure default extractor
 	if w.fielder == nil {
 		w.fielder = formatter.Map(w.formatKey)
 	}
 
+	if w.align {
+		w.columns = newColumns(w.columnWidths)
+	}
+
+	if w.humanize || len(w.humanizers) > 0 {
+		h := make(formatter.Humanizers, len(w.humanizers))
+		if w.humanize {
+			h = formatter.DefaultHumanizers(w.color)
+		}
+		for k, v := range w.humanizers {
+			h[k] = v
+		}
+		w.fielder = formatter.Humanize(w.formatKey, h, w.fielder)
+	}
+
+	if w.maxValueLen > 0 {
+		w.fielder = formatter.Truncate(w.maxValueLen, w.color, w.fielder)
+	}
+
+	return w
+}
+
+// To returns a copy of w writing to out. The copy shares the state of w, such
+// as the widths of aligned columns, and is cheaper than a New Writer.
+func (w Writer) To(out io.Writer) Writer {
+	w.out = out
 	return w
 }
 
@@ -256,8 +438,7 @@ func (w Writer) Print(a ...any) (int, error) {
 	if b, err := fmt.Fprint(buf, a...); err != nil {
 		return b, err
 	}
-	b, err := buf.WriteTo(w.out)
-	return int(b), err
+	return w.flush(buf)
 }
 
 func (w Writer) Println(a ...any) (int, error) {
@@ -270,11 +451,11 @@ func (w Writer) Println(a ...any) (int, error) {
 	if b, err := fmt.Fprintln(buf, a...); err != nil {
 		return b, err
 	}
-	b, err := buf.WriteTo(w.out)
-	return int(b), err
+	return w.flush(buf)
 }
 
 // WriteBytes transforms the JSON input with formatters and appends to w.Out.
+// A trailing newline in p is kept, written along with the record.
 func (w Writer) Write(p []byte) (int, error) {
 	var msg any
 	d := json.NewDecoder(bytes.NewReader(p))
@@ -282,123 +463,253 @@ func (w Writer) Write(p []byte) (int, error) {
 	if err := d.Decode(&msg); err != nil {
 		return w.Print(string(p))
 	}
+	if len(p) > 0 && p[len(p)-1] == newline {
+		return w.WriteLine(msg)
+	}
 	return w.WriteAny(msg)
 }
 
 // Write transforms the JSON input with formatters and appends to w.Out.
 func (w Writer) WriteAny(a any) (int, error) {
-	if a == nil {
+	return w.writeAny(a, false)
+}
+
+// WriteLine is WriteAny followed by a newline, in a single write. Nothing is
+// written when the record is filtered out.
+func (w Writer) WriteLine(a any) (int, error) {
+	return w.writeAny(a, true)
+}
+
+func (w Writer) writeAny(a any, line bool) (int, error) {
+	var buf = bufPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		bufPool.Put(buf)
+	}()
+
+	w.format(buf, a)
+	if buf.Len() == 0 {
 		return 0, nil
 	}
+	if line {
+		buf.WriteByte(newline)
+	}
+	return w.flush(buf)
+}
+
+// flush writes buf to w.out while holding the lock shared by all copies of
+// w, so the output of concurrent writes never interleaves.
+func (w Writer) flush(buf *bytes.Buffer) (int, error) {
+	if w.mu != nil {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+	}
+	b, err := buf.WriteTo(w.out)
+	return int(b), err
+}
+
+// format appends the formatted a to buf.
+func (w Writer) format(buf *bytes.Buffer, a any) {
+	if a == nil {
+		return
+	}
 	if m, ok := a.(map[string]any); ok {
-		return w.writeMap(m)
+		w.formatMap(buf, m)
+		return
 	}
 	if b, ok := a.([]byte); ok {
-		return w.Print(b)
+		fmt.Fprint(buf, b)
+		return
 	}
 	switch reflect.TypeOf(a).Kind() {
 	case reflect.Array, reflect.Slice:
-		return w.writeArray(a.([]any))
+		w.formatArray(buf, a.([]any))
+		return
 	}
-	return w.Print(a)
+	fmt.Fprint(buf, a)
 }
 
-func (w Writer) writeMap(a map[string]any) (int, error) {
+func (w Writer) formatMap(out *bytes.Buffer, a map[string]any) {
 	var buf = bufPool.Get().(*bytes.Buffer)
 	defer func() {
 		buf.Reset()
 		bufPool.Put(buf)
 	}()
 
-	if len(w.includeLevels) > 0 {
-		levels := formatter.GetLevels(a)
-		for _, l := range levels {
-			if !gu.Includes(w.includeLevels, l) {
-				return 0, nil
+	if !formatter.MatchLevels(a, w.includeLevels) {
+		return
+	}
+
+	// indent is the column the message starts at, used for wrapping
+	indent := 0
+	aligned := w.align
+	// w is a copy, so the keys do not carry over to other records
+	w.recordKeys = nil
+	for i, p := range w.pinOrder {
+		if p == PinMessage {
+			if buf.Len() > 0 {
+				indent = formatter.VisibleLen(buf.String()) + 1
 			}
+			aligned = false
 		}
+		// without a pinned message the last pin is left unpadded
+		keys := w.writePinned(buf, a, p, aligned && i < len(w.pinOrder)-1)
+		w.recordKeys = append(w.recordKeys, keys...)
 	}
 
-	for _, p := range w.pinOrder {
-		w.writePinned(buf, a, p)
-	}
+	fields := w.fields(a, "", "", 0, nil)
 
 	// Write space only if something has already been written to the buffer and we are going to write
 	// a key which was not pinned
-	if buf.Len() > 0 {
-		for key := range a {
-			if !gu.Includes(w.excludeKeys, key) {
-				buf.WriteByte(defaultSep)
-				break
-			}
-		}
+	if buf.Len() > 0 && len(fields) > 0 {
+		buf.WriteByte(defaultSep)
 	}
 
-	w.writeFields(buf, a, "")
-	b, err := buf.WriteTo(w.out)
-	return int(b), err
+	w.writeFields(buf, fields)
+	if w.align {
+		// drop padding of trailing empty columns
+		buf.Truncate(len(bytes.TrimRight(buf.Bytes(), string(defaultSep))))
+	}
+	if w.wrap > 0 && !w.vertical {
+		out.WriteString(wrap(buf.String(), w.wrap, indent))
+		return
+	}
+	_, _ = buf.WriteTo(out)
 }
 
-func (w Writer) writeArray(a []any) (int, error) {
-	b, err := w.Print("[\n")
-	if err != nil {
-		return b, err
-	}
+func (w Writer) formatArray(buf *bytes.Buffer, a []any) {
+	buf.WriteString("[\n")
 	for _, v := range a {
-		c, err := w.WriteAny(v)
-		b += c
-		if err != nil {
-			return b, err
-		}
-		if c > 0 {
-			c, err = w.Print("\n")
-			b += c
-			if err != nil {
-				return b, err
-			}
+		n := buf.Len()
+		w.format(buf, v)
+		if buf.Len() > n {
+			buf.WriteByte(newline)
 		}
 	}
-	c, err := w.Print("]")
-	b += c
-	return b, err
+	buf.WriteString("]")
 }
 
-// writeFields appends formatted key-value pairs to buf.
-func (w Writer) writeFields(buf *bytes.Buffer, evt map[string]any, prefix string) {
+// field is a key-value pair to be written after the pinned parts.
+type field struct {
+	key   string
+	value any
+}
+
+// fields collects the non-excluded key-value pairs of evt, in output order,
+// flattening nested objects and arrays when enabled. path is the dotted path
+// used for exclusion, prefix is the key prefix used for output.
+func (w Writer) fields(evt map[string]any, path string, prefix string, depth int, out []field) []field {
 	var keys = make([]string, 0, len(evt))
 	for key := range evt {
-		if gu.Includes(w.excludeKeys, prefix+key) {
+		if w.excluded(path + key) {
 			continue
 		}
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
 
-	for i, key := range keys {
-		value := evt[key]
-		if m, ok := value.(map[string]any); ok && w.flatten {
-			w.writeFields(buf, m, prefix+key+".")
-		} else {
-			if w.vertical {
-				buf.WriteByte(newline)
-				buf.WriteByte(tab)
+	for _, key := range keys {
+		out = w.field(evt[key], path+key, prefix+key, depth, out)
+	}
+	return out
+}
+
+// field appends value to out, flattening it when enabled.
+func (w Writer) field(value any, path string, key string, depth int, out []field) []field {
+	if w.flatten && (w.flattenDepth == 0 || depth < w.flattenDepth) {
+		switch v := value.(type) {
+		case map[string]any:
+			if len(v) > 0 {
+				return w.fields(v, path+formatter.PathSep, key+w.flattenSep, depth+1, out)
+			}
+		case []any:
+			if len(v) > 0 {
+				for i, vv := range v {
+					idx := strconv.Itoa(i)
+					if w.excluded(path + formatter.PathSep + idx) {
+						continue
+					}
+					out = w.field(vv, path+formatter.PathSep+idx, key+w.flattenSep+idx, depth+1, out)
+				}
+				return out
+			}
+		}
+	}
+	if v, ok := value.(map[string]any); ok && len(v) > 0 {
+		if value = w.prune(v, path); len(value.(map[string]any)) == 0 {
+			return out
+		}
+	}
+	return append(out, field{key: key, value: value})
+}
+
+// excluded reports whether the field at path is hidden.
+func (w Writer) excluded(path string) bool {
+	return gu.Includes(w.excludeKeys, path) || gu.Includes(w.recordKeys, path)
+}
+
+// prune returns a copy of the object at path without the record keys nested
+// in it, and without the objects left empty by them. The object is returned as
+// is when it holds none.
+func (w Writer) prune(obj map[string]any, path string) map[string]any {
+	prefix := path + formatter.PathSep
+	nested := false
+	for _, k := range w.recordKeys {
+		if strings.HasPrefix(k, prefix) {
+			nested = true
+			break
+		}
+	}
+	if !nested {
+		return obj
+	}
+	out := make(map[string]any, len(obj))
+	for k, v := range obj {
+		if gu.Includes(w.recordKeys, prefix+k) {
+			continue
+		}
+		if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+			if sub = w.prune(sub, prefix+k); len(sub) == 0 {
+				continue
 			}
-			buf.WriteString(w.fielder(prefix+key, value))
+			v = sub
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// writeFields appends formatted key-value pairs to buf.
+func (w Writer) writeFields(buf *bytes.Buffer, fields []field) {
+	for i, f := range fields {
+		if w.vertical {
+			buf.WriteByte(newline)
+			buf.WriteByte(tab)
 		}
+		buf.WriteString(w.fielder(f.key, f.value))
 		// Skip space for last key
-		if i < len(keys)-1 {
+		if i < len(fields)-1 {
 			buf.WriteByte(defaultSep)
 		}
 	}
 }
 
-// writePinned appends a formatted part to buf.
-func (w Writer) writePinned(buf *bytes.Buffer, evt map[string]any, p string) {
+// writePinned appends a formatted part to buf, returning the keys it used for
+// the record if its formatter hides them per record.
+func (w Writer) writePinned(buf *bytes.Buffer, evt map[string]any, p string, align bool) []string {
 	var s string
-	if f, ok := w.formatter[p]; ok {
+	var keys []string
+	if f, ok := w.formatter[p].(formatter.RecordFormatter); ok {
+		s, keys = f.FormatRecord(evt)
+	} else if f, ok := w.formatter[p]; ok {
 		s = f.Format(evt)
 	} else {
-		s = w.fielder(p, evt[p])
+		v, _ := formatter.Lookup(evt, p)
+		s = w.fielder(p, v)
+	}
+
+	if align {
+		s = w.columns.pad(p, s)
 	}
 
 	if len(s) > 0 {
@@ -408,4 +719,38 @@ func (w Writer) writePinned(buf *bytes.Buffer, evt map[string]any, p string) {
 		}
 		buf.WriteString(s)
 	}
+	return keys
+}
+
+// wrap breaks s at spaces so lines fit within width, indenting continuation
+// lines by indent. Words longer than a line are not broken.
+func wrap(s string, width int, indent int) string {
+	if indent >= width/2 {
+		indent = 0
+	}
+	pad := strings.Repeat(string(defaultSep), indent)
+
+	var sb strings.Builder
+	for i, line := range strings.Split(s, string(newline)) {
+		if i > 0 {
+			sb.WriteByte(newline)
+		}
+		col := 0
+		for j, word := range strings.Split(line, string(defaultSep)) {
+			n := formatter.VisibleLen(word)
+			if j > 0 {
+				if col > indent && col+1+n > width {
+					sb.WriteByte(newline)
+					sb.WriteString(pad)
+					col = indent
+				} else {
+					sb.WriteByte(defaultSep)
+					col++
+				}
+			}
+			sb.WriteString(word)
+			col += n
+		}
+	}
+	return sb.String()
 }
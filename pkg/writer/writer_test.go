@@ -3,6 +3,9 @@ package writer_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/dcilke/golden"
@@ -111,3 +114,361 @@ func TestConsole_Vertical(t *testing.T) {
 		})
 	}
 }
+
+func TestConsole_Flatten(t *testing.T) {
+	nested := j{
+		"msg": "message",
+		"request": j{
+			"url":   "foo",
+			"items": a{j{"id": 1}, j{"id": 2}},
+			"empty": a{},
+		},
+	}
+	testcases := map[string]struct {
+		opts []writer.Option
+		in   any
+	}{
+		"default":   {nil, nested},
+		"depth":     {[]writer.Option{writer.WithFlattenDepth(1)}, nested},
+		"separator": {[]writer.Option{writer.WithFlattenSeparator("_")}, nested},
+		"exclude":   {[]writer.Option{writer.WithExcludeKeys([]string{"request.items.0"})}, nested},
+		"pin":       {[]writer.Option{writer.WithPinOrder([]string{"request.items.1.id"})}, nested},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(append([]writer.Option{
+				writer.WithOut(buf),
+				writer.WithColor(false),
+				writer.WithFlatten(true),
+			}, tc.opts...)...)
+			b, err := json.Marshal(tc.in)
+			require.NoError(t, err)
+			o, err := w.Write(b)
+			require.True(t, o > 0)
+			require.NoError(t, err)
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestConsole_ExcludeKeys(t *testing.T) {
+	testcases := map[string]struct {
+		opts   []writer.Option
+		expect string
+	}{
+		"replace": {[]writer.Option{
+			writer.WithExcludeKeys([]string{"a"}),
+			writer.WithExcludeKeys([]string{"b"}),
+		}, "<nil> message a=1 c=3"},
+		"append": {[]writer.Option{
+			writer.WithExcludeKeys([]string{"a"}),
+			writer.WithExcludeKeysAppend([]string{"b"}),
+		}, "<nil> message c=3"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(append([]writer.Option{
+				writer.WithOut(buf),
+				writer.WithColor(false),
+			}, tc.opts...)...)
+			_, err := w.Write([]byte(`{"msg":"message","a":1,"b":2,"c":3}`))
+			require.NoError(t, err)
+			require.Equal(t, tc.expect, buf.String())
+		})
+	}
+}
+
+func TestConsole_MaxValueLen(t *testing.T) {
+	testcases := map[string]any{
+		"string": j{"msg": "message", "body": "aGVsbG8gd29ybGQgaGVsbG8gd29ybGQ="},
+		"object": j{"msg": "message", "query": j{"sql": "SELECT * FROM users WHERE id = 1"}},
+		"short":  j{"msg": "message", "foo": "bar"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(
+				writer.WithOut(buf),
+				writer.WithColor(false),
+				writer.WithMaxValueLen(12),
+			)
+			b, err := json.Marshal(tc)
+			require.NoError(t, err)
+			o, err := w.Write(b)
+			require.True(t, o > 0)
+			require.NoError(t, err)
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestConsole_Wrap(t *testing.T) {
+	testcases := map[string]any{
+		"short": j{"level": "info", "msg": "message", "foo": "bar"},
+		"long": j{
+			"level":     "info",
+			"timestamp": "2022-08-03T12:34:25.605701107Z",
+			"caller":    "main.go:12",
+			"msg":       "a message which is long enough to wrap",
+			"foo":       "bar",
+			"bin":       "baz",
+			"user":      "someone@example.com",
+		},
+		"no-pin": j{"foo": "bar", "bin": "baz", "user": "someone@example.com", "tenant": "acme"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(
+				writer.WithOut(buf),
+				writer.WithColor(false),
+				writer.WithWrap(60),
+			)
+			b, err := json.Marshal(tc)
+			require.NoError(t, err)
+			o, err := w.Write(b)
+			require.True(t, o > 0)
+			require.NoError(t, err)
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestConsole_Align(t *testing.T) {
+	records := a{
+		j{"level": "info", "caller": "main.go:12", "msg": "first"},
+		j{"level": "warn", "caller": "pkg/writer/writer.go:120", "msg": "second", "foo": "bar"},
+		j{"level": "debug", "msg": "third"},
+		j{"level": "info", "caller": "main.go:12"},
+	}
+	testcases := map[string][]writer.Option{
+		"adaptive": nil,
+		"fixed":    {writer.WithColumnWidth(writer.PinCaller, 16)},
+		"no-message": {writer.WithPinOrder([]string{
+			writer.PinLevel,
+			writer.PinCaller,
+		})},
+	}
+	for name, opts := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(append([]writer.Option{
+				writer.WithOut(buf),
+				writer.WithColor(false),
+				writer.WithAlign(true),
+			}, opts...)...)
+			for _, r := range records {
+				b, err := json.Marshal(r)
+				require.NoError(t, err)
+				o, err := w.Write(b)
+				require.True(t, o > 0)
+				require.NoError(t, err)
+				_, err = w.Println()
+				require.NoError(t, err)
+			}
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestConsole_HTTP(t *testing.T) {
+	testcases := map[string]any{
+		"request": j{
+			"level":    "info",
+			"msg":      "request",
+			"method":   "GET",
+			"path":     "/api/users",
+			"status":   200,
+			"duration": 12,
+			"user":     "someone",
+		},
+		"ecs": j{
+			"level": "warn",
+			"msg":   "request",
+			"http": j{
+				"request":  j{"method": "POST"},
+				"response": j{"status_code": 404},
+			},
+			"url": j{"path": "/login"},
+		},
+		"ecs-nested": j{
+			"level": "info",
+			"msg":   "request",
+			"http": j{
+				"request":  j{"method": "GET", "id": "abc"},
+				"response": j{"status_code": 200},
+			},
+			"url":    j{"path": "/api", "domain": "localhost"},
+			"client": j{"ip": "10.0.0.1"},
+		},
+		"durations": j{
+			"level":    "info",
+			"msg":      "request",
+			"method":   "GET",
+			"path":     "/",
+			"duration": 12,
+			"latency":  3,
+		},
+		"other": j{"level": "info", "msg": "started"},
+		"not-request": j{
+			"level":    "info",
+			"msg":      "job done",
+			"status":   "ok",
+			"duration": 5,
+			"url":      j{"domain": "localhost"},
+		},
+		"path-only": j{"level": "info", "msg": "opened", "path": "/tmp/x"},
+	}
+	for name, tc := range testcases {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			w := writer.New(
+				writer.WithOut(buf),
+				writer.WithColor(false),
+				writer.WithPinBefore(writer.PinHTTP, writer.PinMessage),
+			)
+			b, err := json.Marshal(tc)
+			require.NoError(t, err)
+			o, err := w.Write(b)
+			require.True(t, o > 0)
+			require.NoError(t, err)
+			golden.Assert(t, buf.Bytes())
+		})
+	}
+}
+
+func TestConsole_WriteLine(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := writer.New(
+		writer.WithOut(buf),
+		writer.WithColor(false),
+		writer.WithLevelFilter("error"),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+	)
+	n, err := w.WriteLine(j{"level": "info", "message": "filtered"})
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	n, err = w.WriteLine(j{"level": "error", "message": "kept"})
+	require.NoError(t, err)
+	require.Equal(t, "ERR kept\n", buf.String())
+	require.Equal(t, buf.Len(), n)
+
+	buf.Reset()
+	_, err = w.Write([]byte(`{"level":"error","message":"line"}` + "\n"))
+	require.NoError(t, err)
+	require.Equal(t, "ERR line\n", buf.String())
+}
+
+func TestConsole_Zero(t *testing.T) {
+	buf := new(bytes.Buffer)
+	var w writer.Writer
+	require.NotPanics(t, func() {
+		_, err := w.To(buf).Println("plain")
+		require.NoError(t, err)
+	})
+	require.Equal(t, "plain\n", buf.String())
+}
+
+// upper pins the value of key in upper case.
+type upper string
+
+func (u upper) Format(m map[string]any) string {
+	s, _ := m[string(u)].(string)
+	return strings.ToUpper(s)
+}
+
+func (u upper) ExcludeKeys() []string {
+	return []string{string(u)}
+}
+
+func TestConsole_CustomPin(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := writer.New(
+		writer.WithOut(buf),
+		writer.WithColor(false),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+		writer.WithFormatter("service", upper("svc")),
+		writer.WithPinBefore("service", writer.PinMessage),
+	)
+	_, err := w.WriteLine(j{"level": "info", "message": "started", "svc": "api", "port": 80})
+	require.NoError(t, err)
+	require.Equal(t, "INF API started port=80\n", buf.String())
+}
+
+// writes counts the calls to Write, keeping each one as written.
+type writes struct {
+	calls [][]byte
+}
+
+func (w *writes) Write(p []byte) (int, error) {
+	w.calls = append(w.calls, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func TestConsole_SingleWrite(t *testing.T) {
+	out := &writes{}
+	w := writer.New(
+		writer.WithOut(out),
+		writer.WithColor(false),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+	)
+	_, err := w.WriteLine(a{
+		j{"level": "info", "message": "first"},
+		a{j{"level": "info", "message": "nested"}},
+		"text",
+	})
+	require.NoError(t, err)
+	require.Len(t, out.calls, 1)
+	require.Equal(t, "[\nINF first\n[\nINF nested\n]\ntext\n]\n", string(out.calls[0]))
+}
+
+func TestConsole_Concurrent(t *testing.T) {
+	const goroutines, records = 8, 50
+
+	buf := new(bytes.Buffer)
+	w := writer.New(
+		writer.WithOut(buf),
+		writer.WithColor(false),
+		writer.WithAlign(true),
+		writer.WithPinOrder([]string{writer.PinLevel, writer.PinMessage}),
+	)
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// copies of the Writer share its lock
+			w := w
+			for n := 0; n < records; n++ {
+				msg := fmt.Sprintf("g%d-%d", i, n)
+				if n%2 == 0 {
+					_, _ = w.WriteLine(j{"level": "info", "message": msg})
+					continue
+				}
+				_, _ = w.Write([]byte(fmt.Sprintf(`[{"level":"warn","message":"%s-a"},{"level":"warn","message":"%s-b"}]`+"\n", msg, msg)))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	seen := 0
+	for i := 0; i < len(lines); i++ {
+		if lines[i] != "[" {
+			require.Regexp(t, `^INF g\d+-\d+$`, lines[i])
+			seen++
+			continue
+		}
+		// the elements of an array are never split by another record
+		require.Greater(t, len(lines), i+3)
+		msg := strings.TrimSuffix(lines[i+1], "-a")
+		require.Regexp(t, `^WRN g\d+-\d+$`, msg)
+		require.Equal(t, []string{msg + "-b", "]"}, lines[i+2:i+4])
+		seen++
+		i += 3
+	}
+	require.Equal(t, goroutines*records, seen)
+}
@@ -0,0 +1,26 @@
+//go:build !darwin && !linux
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// termWidth is not supported on this platform.
+func termWidth(f *os.File) int {
+	return 0
+}
+
+// termSize is not supported on this platform.
+func termSize(f *os.File) (int, int) {
+	return 0, 0
+}
+
+// makeRaw is not supported on this platform.
+func makeRaw(f *os.File) (func(), error) {
+	return nil, errors.New("terminal raw mode is not supported on this platform")
+}
+
+// notifyResize is not supported on this platform.
+func notifyResize(c chan<- os.Signal) {}
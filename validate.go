@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/dcilke/gu"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	keyDefaultProfile = "default_profile"
+	keyProfiles       = "profiles"
+	keyExtends        = "extends"
+)
+
+// configAliases maps renamed option keys to their current key.
+var configAliases = map[string]string{
+	"plain": "raw",
+}
+
+// ConfigError is a problem found at a position in a config file.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// validateConfig reports unknown keys and malformed profiles in the config
+// document, and rewrites renamed keys to their current spelling.
+func validateConfig(file string, doc *yaml.Node) error {
+	node := doc
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+	if node.Kind == 0 {
+		return nil
+	}
+
+	v := validator{file: file}
+	if !v.mapping(node, "config") {
+		return v.err()
+	}
+
+	keys := optionKeys()
+	v.keys(node, append(keys, keyDefaultProfile, keyProfiles))
+	for i := 0; i < len(node.Content)-1; i += 2 {
+		if node.Content[i].Value != keyProfiles {
+			continue
+		}
+		profiles := node.Content[i+1]
+		if !v.mapping(profiles, keyProfiles) {
+			continue
+		}
+		for j := 0; j < len(profiles.Content)-1; j += 2 {
+			p := profiles.Content[j+1]
+			if v.mapping(p, fmt.Sprintf("profile %q", profiles.Content[j].Value)) {
+				v.keys(p, append(keys, keyExtends))
+			}
+		}
+	}
+	return v.err()
+}
+
+type validator struct {
+	file string
+	errs []error
+}
+
+func (v *validator) errorf(n *yaml.Node, format string, a ...any) {
+	v.errs = append(v.errs, &ConfigError{
+		File:   v.file,
+		Line:   n.Line,
+		Column: n.Column,
+		Msg:    fmt.Sprintf(format, a...),
+	})
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+// mapping reports whether n is a mapping, recording an error if not.
+func (v *validator) mapping(n *yaml.Node, what string) bool {
+	if n.Kind == yaml.MappingNode {
+		return true
+	}
+	// an empty value, e.g. "profiles:" with nothing below it
+	if n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return false
+	}
+	v.errorf(n, "%s must be a mapping", what)
+	return false
+}
+
+// keys checks the keys of mapping n against known, renaming aliases and
+// dropping duplicates in place.
+func (v *validator) keys(n *yaml.Node, known []string) {
+	seen := make(map[string]bool, len(n.Content)/2)
+	content := n.Content[:0]
+	for i := 0; i < len(n.Content)-1; i += 2 {
+		k := n.Content[i]
+		name := k.Value
+		if alias, ok := configAliases[k.Value]; ok {
+			k.Value = alias
+		}
+		if seen[k.Value] {
+			if name != k.Value {
+				v.errorf(k, "%q is a renamed %q which is already set", name, k.Value)
+			} else {
+				v.errorf(k, "%q is set more than once", k.Value)
+			}
+			continue
+		}
+		seen[k.Value] = true
+		content = append(content, k, n.Content[i+1])
+		if gu.Includes(known, k.Value) {
+			continue
+		}
+		if s := suggest(k.Value, known); s != "" {
+			v.errorf(k, "unknown key %q, did you mean %q?", k.Value, s)
+		} else {
+			v.errorf(k, "unknown key %q", k.Value)
+		}
+	}
+	n.Content = content
+}
+
+// optionKeys returns the config keys of the options in Cmd.
+func optionKeys() []string {
+	keys := make([]string, 0, len(cmdKeys()))
+	for k := range cmdKeys() {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the known key closest to key, if any is close enough.
+func suggest(key string, known []string) string {
+	// flag names and other casings of a key, e.g. no-pin or no_pin for noPin
+	for _, k := range known {
+		if normalizeKey(k) == normalizeKey(key) {
+			return k
+		}
+	}
+	for alias, k := range configAliases {
+		if normalizeKey(alias) == normalizeKey(key) {
+			return k
+		}
+	}
+
+	best, dist := "", len(key)/3+1
+	for _, k := range known {
+		if d := levenshtein(key, k); d <= dist && (best == "" || d < dist) {
+			best, dist = k, d
+		}
+	}
+	return best
+}
+
+func normalizeKey(s string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(s))
+}
+
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if c := cur[j-1] + 1; c < cur[j] {
+				cur[j] = c
+			}
+			if c := prev[j-1] + cost; c < cur[j] {
+				cur[j] = c
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
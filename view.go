@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dcilke/heron"
+	"github.com/dcilke/hz/pkg/pager"
+)
+
+const (
+	ttyPath = "/dev/tty"
+
+	altScreenOn  = "\x1b[?1049h\x1b[?25l"
+	altScreenOff = "\x1b[?25h\x1b[?1049l"
+	cursorHome   = "\x1b[H"
+	clearLine    = "\x1b[K"
+
+	// redrawInterval limits how often the screen is redrawn for new input.
+	redrawInterval = 50 * time.Millisecond
+)
+
+// viewCommand browses the input in an interactive pager.
+type viewCommand struct {
+	Size int `long:"size" default:"10000" description:"number of records to keep, older records are dropped"`
+
+	cmd *Cmd
+}
+
+func (c *viewCommand) Execute(args []string) error {
+	// levels are toggled in the pager, the writer shows every record it is given
+	opts := *c.cmd
+	opts.Level = nil
+	p := pager.New(c.Size, !c.cmd.Raw, writerOptions(&opts)...)
+	p.ShowLevels(c.cmd.Level)
+
+	dirty := make(chan struct{}, 1)
+	changed := func() {
+		select {
+		case dirty <- struct{}{}:
+		default:
+		}
+	}
+	record, err := normalized(c.cmd, func(a any) {
+		p.Add(a)
+		changed()
+	})
+	if err != nil {
+		return err
+	}
+
+	// keys are read from the terminal so the input can be piped in, reading
+	// records from it too would take the keys as input
+	if len(args) == 0 && termWidth(os.Stdin) > 0 {
+		return errors.New("hz view needs a FILE or piped input")
+	}
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("unable to open terminal: %w", err)
+	}
+	defer tty.Close()
+	if w, _ := termSize(tty); w == 0 {
+		return errors.New("hz view needs a terminal")
+	}
+	// keys do not raise signals in raw mode, others are caught so the terminal
+	// is restored on the way out
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(stop)
+	restore, err := makeRaw(tty)
+	if err != nil {
+		return fmt.Errorf("unable to configure terminal: %w", err)
+	}
+	defer restore()
+	fmt.Fprint(tty, altScreenOn)
+	defer fmt.Fprint(tty, altScreenOff)
+
+	h := heron.New(
+		heron.WithJSON(record),
+		heron.WithBytes(func(b []byte) {
+			p.Text(b)
+			changed()
+		}),
+		heron.WithError(func(err error) {}),
+	)
+	go func() {
+		process(args, func(f *os.File) {
+			h.Process(f)
+		})
+		h.Flush()
+		p.Done()
+		changed()
+	}()
+
+	keys := make(chan []string)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := tty.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- pager.ParseKeys(buf[:n])
+		}
+	}()
+
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+	tick := time.NewTicker(redrawInterval)
+	defer tick.Stop()
+
+	draw(tty, p)
+	pending := false
+	for {
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				if p.Key(k) {
+					return nil
+				}
+			}
+			draw(tty, p)
+		case <-resize:
+			draw(tty, p)
+		case <-stop:
+			return nil
+		case <-dirty:
+			pending = true
+		case <-tick.C:
+			if pending {
+				pending = false
+				draw(tty, p)
+			}
+		}
+	}
+}
+
+// draw writes the pager's screen to the terminal in one write.
+func draw(tty *os.File, p *pager.Pager) {
+	w, h := termSize(tty)
+	var sb strings.Builder
+	sb.WriteString(cursorHome)
+	for i, line := range p.Render(w, h) {
+		if i > 0 {
+			sb.WriteString("\r\n")
+		}
+		sb.WriteString(line + clearLine)
+	}
+	_, _ = tty.WriteString(sb.String())
+}